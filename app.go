@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// GitHub App to authenticate as, set with -app-id, -app-installation-id and -app-private-key or
+// GITHUB_APP_ID, GITHUB_APP_INSTALLATION_ID and GITHUB_APP_PRIVATE_KEY_FILE
+var (
+	appID             string
+	appInstallationID int64
+	appPrivateKeyFile string
+)
+
+// Fills in the GitHub App settings from the environment where the flags didn't set them
+func appFromEnv() error {
+	if appID == "" {
+		appID = os.Getenv("GITHUB_APP_ID")
+	}
+	if appInstallationID == 0 {
+		if value := os.Getenv("GITHUB_APP_INSTALLATION_ID"); value != "" {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || id <= 0 {
+				return fmt.Errorf("GITHUB_APP_INSTALLATION_ID must be a positive number, got %q", value)
+			}
+			appInstallationID = id
+		}
+	}
+	if appPrivateKeyFile == "" {
+		appPrivateKeyFile = os.Getenv("GITHUB_APP_PRIVATE_KEY_FILE")
+	}
+
+	return nil
+}
+
+// Gets the GitHub App to authenticate as, or nil when none was set up
+func loadAppAuth() (*scan.App, error) {
+	if err := appFromEnv(); err != nil {
+		return nil, err
+	}
+	if appID == "" && appInstallationID == 0 && appPrivateKeyFile == "" {
+		return nil, nil
+	}
+	if appID == "" || appInstallationID == 0 || appPrivateKeyFile == "" {
+		return nil, fmt.Errorf("-app-id, -app-installation-id and -app-private-key must all be set to authenticate as a GitHub App")
+	}
+
+	data, err := os.ReadFile(appPrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := scan.ParseAppKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", appPrivateKeyFile, err)
+	}
+
+	return &scan.App{ID: appID, InstallationID: appInstallationID, Key: key}, nil
+}
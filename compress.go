@@ -0,0 +1,58 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// How findings files are compressed, set with -compress ("" leaves -output to go by its extension)
+var outputCompression string
+
+// Sets the compression from a flag value
+func setCompression(value string) error {
+	switch value {
+	case "gzip", "none":
+		outputCompression = value
+		return nil
+	default:
+		return fmt.Errorf("compression must be gzip or none, got %q", value)
+	}
+}
+
+// Whether a findings file at path gets compressed, which -output does by itself when it ends in .gz
+func compressing(path string) bool {
+	return outputCompression == "gzip" || outputCompression == "" && strings.HasSuffix(path, ".gz")
+}
+
+// gzipFile gzips what's written to a file, closing the file after the gzip trailer
+//
+// The compressed stream is flushed after every write, so whatever a reporter flushes can be read back from the
+// file while the scan is still going.
+type gzipFile struct {
+	gz   *gzip.Writer
+	file io.WriteCloser
+}
+
+func newGzipFile(file io.WriteCloser) *gzipFile {
+	return &gzipFile{gz: gzip.NewWriter(file), file: file}
+}
+
+func (f *gzipFile) Write(b []byte) (int, error) {
+	n, err := f.gz.Write(b)
+	if err != nil {
+		return n, err
+	}
+
+	return n, f.gz.Flush()
+}
+
+func (f *gzipFile) Close() error {
+	err := f.gz.Close()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
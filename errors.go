@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// Whether operational errors go to stderr as JSON lines, set with -errors-json
+var errorsJSON bool
+
+// Exit statuses, with exitError also being what the flag package exits with for bad flags
+const (
+	exitFound       = 1
+	exitError       = 2
+	exitInterrupted = 130
+)
+
+// Whether an error, rather than just a warning, was logged, which makes the run exit with exitError
+var errorsLogged atomic.Bool
+
+// Logs an error that stops the run before it gets going, and exits
+func fatalf(format string, args ...any) {
+	errorf(format, args...)
+	os.Exit(exitError)
+}
+
+// Where JSON error records are written
+var errorOutput io.Writer = progress.around(os.Stderr)
+
+// errorRecord is an operational error as written by -errors-json
+type errorRecord struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Kind    string `json:"kind"`
+	Account string `json:"account,omitempty"`
+	Repo    string `json:"repo,omitempty"`
+	Op      string `json:"op"`
+	Error   string `json:"error"`
+}
+
+// Sorts an error into a kind that automation can act on
+func errorKind(err error) string {
+	var httpErr *scan.HTTPError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &httpErr) && httpErr.RateLimited:
+		return "rate_limited"
+	case errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound:
+		return "not_found"
+	case errors.As(err, &httpErr):
+		return "http"
+	case errors.As(err, &netErr):
+		return "network"
+	default:
+		return "other"
+	}
+}
+
+// Logs an operational error that happened while doing op, as text or as a JSON line
+//
+// Errors below -log-level aren't logged either way, and fatal ones count as error.
+func logError(level, op, account, repo string, err error) {
+	severity, ok := logLevelNames[level]
+	if !ok {
+		severity = slog.LevelError
+	}
+	if severity >= slog.LevelError {
+		errorsLogged.Store(true)
+	}
+	if !logEnabled(severity) {
+		return
+	}
+
+	if !errorsJSON {
+		switch {
+		case repo != "":
+			logf(severity, "%s %s/%s: %v", op, account, repo, err)
+		case account != "":
+			logf(severity, "%s %s: %v", op, account, err)
+		default:
+			logf(severity, "%s: %v", op, err)
+		}
+		return
+	}
+
+	record := errorRecord{
+		Time:    time.Now().UTC().Format(time.RFC3339),
+		Level:   level,
+		Kind:    errorKind(err),
+		Account: account,
+		Repo:    repo,
+		Op:      op,
+		Error:   err.Error(),
+	}
+	if err := json.NewEncoder(errorOutput).Encode(record); err != nil {
+		errorf("writing error record: %v", err)
+	}
+}
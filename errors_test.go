@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+func TestErrorsJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	status, _, stderr := runGitwikiWithInput(t, "", "-skip-token-check", "-quiet", "-summary=false", "-probe-retries", "0",
+		"-errors-json", "url:"+srv.URL+"/acme/docs")
+	if status != exitError {
+		t.Fatalf("exit status = %d, want %d\nstderr:\n%s", status, exitError, stderr)
+	}
+
+	var records []errorRecord
+	for _, line := range strings.Split(strings.TrimSpace(stderr), "\n") {
+		var record errorRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("stderr line isn't a JSON error record: %v\n%s", err, line)
+		}
+		records = append(records, record)
+	}
+
+	if len(records) != 1 {
+		t.Fatalf("got %d error records, want 1: %+v", len(records), records)
+	}
+	r := records[0]
+	if r.Level != "error" || r.Kind != "http" || r.Op != "probe" || r.Repo != "acme/docs" || !strings.Contains(r.Error, "500") {
+		t.Errorf("error record is %+v", r)
+	}
+	if r.Time == "" || r.Account == "" {
+		t.Errorf("error record is missing its time or account: %+v", r)
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: &scan.HTTPError{StatusCode: http.StatusForbidden, RateLimited: true}, want: "rate_limited"},
+		{err: &scan.HTTPError{StatusCode: http.StatusNotFound}, want: "not_found"},
+		{err: &scan.HTTPError{StatusCode: http.StatusBadGateway}, want: "http"},
+		{err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: "network"},
+		{err: errors.New("bad news"), want: "other"},
+	}
+	for _, tt := range tests {
+		if got := errorKind(tt.err); got != tt.want {
+			t.Errorf("errorKind(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
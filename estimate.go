@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// Writes what scanning the accounts would cost against the rate limit, without scanning them
+func estimateScan(ctx context.Context, scanner *scan.Scanner, accounts []string, w io.Writer) error {
+	e, err := scanner.Estimate(ctx, accounts)
+	if err != nil {
+		return err
+	}
+
+	for _, orgName := range accounts {
+		if orgName != "" {
+			fmt.Fprintf(w, "Account: %s, Repositories: %d\n", orgName, e.Accounts[orgName])
+		}
+	}
+	fmt.Fprintf(w, "Total repositories: %d\n", e.Repositories)
+	fmt.Fprintf(w, "API calls: %d\n", e.APICalls)
+	fmt.Fprintf(w, "Wiki probes: up to %d\n", e.MaxProbes)
+	if e.Filtered {
+		fmt.Fprintln(w, "Repositories are counted before the filters, which will scan fewer of them")
+	}
+	fmt.Fprintf(w, "Rate limit: %d of %d remaining, resets at %s\n", e.Rate.Remaining, e.Rate.Limit, time.Unix(e.Rate.Reset, 0).Format(time.RFC3339))
+	if e.Fits() {
+		fmt.Fprintln(w, "Fits in rate limit budget: yes")
+	} else {
+		fmt.Fprintln(w, "Fits in rate limit budget: no")
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+func TestEstimateScanWritesTheBudget(t *testing.T) {
+	for _, tt := range []struct {
+		remaining int
+		want      string
+	}{
+		{remaining: 3, want: "Fits in rate limit budget: yes\n"},
+		{remaining: 2, want: "Fits in rate limit budget: no\n"},
+	} {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/orgs/acme/repos", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/orgs/acme/repos?per_page=1&page=201>; rel="last"`, r.Host))
+			fmt.Fprint(w, `[{"name": "first"}]`)
+		})
+		mux.HandleFunc("/rate_limit", respond(http.StatusOK, fmt.Sprintf(`{"resources": {"core": {"limit": 5000, "remaining": %d, "reset": 1767225600}}}`, tt.remaining)))
+		srv := httptest.NewServer(mux)
+		defer srv.Close()
+		scanner := scan.NewScanner()
+		scanner.APIURL = srv.URL
+
+		var out strings.Builder
+		if err := estimateScan(context.Background(), scanner, []string{"org:acme"}, &out); err != nil {
+			t.Fatal(err)
+		}
+		// 201 repositories take three pages to list
+		for _, want := range []string{"Account: org:acme, Repositories: 201\n", "Total repositories: 201\n", "API calls: 3\n", fmt.Sprintf("Rate limit: %d of 5000 remaining", tt.remaining), tt.want} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("with %d calls remaining the estimate is missing %q:\n%s", tt.remaining, want, out.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/offftherecord/gitwiki/internal/atomicfile"
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// Default for -finding-files-max, so a huge scan can't fill the disk with small files
+const defaultFindingFilesMax = 10000
+
+// findingFilesReporter writes each finding to its own JSON file named by its fingerprint, for CI systems that
+// collect every file as an artifact
+type findingFilesReporter struct {
+	dir string
+	max int
+
+	written int
+	warned  bool
+}
+
+func newFindingFilesReporter(dir string, max int) (*findingFilesReporter, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &findingFilesReporter{dir: dir, max: max}, nil
+}
+
+func (r *findingFilesReporter) Report(f scan.Finding) error {
+	if r.written >= r.max {
+		if !r.warned {
+			warnf("Wrote the most finding files allowed (%d) to %s, not writing the rest", r.max, r.dir)
+			r.warned = true
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(newFindingRecord(f), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := atomicfile.Create(filepath.Join(r.dir, f.Fingerprint()+".json"))
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.File.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	r.written++
+
+	return nil
+}
+
+// Every finding file is complete once it's written, so there's nothing to flush
+func (r *findingFilesReporter) Flush() error {
+	return nil
+}
+
+func (r *findingFilesReporter) Close() error {
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+func TestFindingFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "findings")
+	r, err := newFindingFilesReporter(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	findings := []scan.Finding{
+		{Account: "acme", Repo: "docs", URL: "https://github.com/acme/docs/wiki", Result: scan.ProbeWriteable},
+		{Account: "acme", Repo: "blank", URL: "https://github.com/acme/blank/wiki", Result: scan.ProbeEmpty},
+		{Account: "acme", Repo: "extra", URL: "https://github.com/acme/extra/wiki", Result: scan.ProbeEmpty},
+	}
+	for _, f := range findings {
+		if err := r.Report(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The third finding is over -finding-files-max
+	if len(entries) != 2 {
+		t.Fatalf("wrote %d files, want 2", len(entries))
+	}
+	for _, f := range findings[:2] {
+		data, err := os.ReadFile(filepath.Join(dir, f.Fingerprint()+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var record findingRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Account != f.Account || record.Repo != f.Repo || record.URL != f.URL || record.Vulnerability != f.Vulnerability() {
+			t.Errorf("%s's file has %+v", f.Repo, record)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// headerFlag collects repeated "Key: Value" flags into a header set
+type headerFlag http.Header
+
+func (h headerFlag) String() string {
+	var pairs []string
+	for key, values := range h {
+		for _, value := range values {
+			pairs = append(pairs, key+": "+value)
+		}
+	}
+
+	return strings.Join(pairs, ", ")
+}
+
+func (h headerFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, ":")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return fmt.Errorf("header must be in the form \"Key: Value\", got %q", value)
+	}
+	http.Header(h).Add(key, strings.TrimSpace(val))
+
+	return nil
+}
+
+// Checks a -repo-visibility value
+func parseVisibility(value string) (string, error) {
+	switch value {
+	case scan.VisibilityPublic, scan.VisibilityPrivate, scan.VisibilityAll:
+		return value, nil
+	default:
+		return "", fmt.Errorf("visibility must be %s, %s or %s, got %q", scan.VisibilityPublic, scan.VisibilityPrivate, scan.VisibilityAll, value)
+	}
+}
+
+// Parses -pushed-since, how long ago such as 24h or an RFC 3339 time
+func parsePushedSince(value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("duration can't be negative, got %q", value)
+		}
+		return time.Now().Add(-d), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("must be a duration such as 24h or an RFC 3339 time, got %q", value)
+	}
+
+	return t, nil
+}
+
+// Parses a -name-regex into the filter repository names have to match
+func parseNameFilter(value string) (scan.Filter, error) {
+	re, err := regexp.Compile(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(r scan.Repository) bool { return re.MatchString(r.Name) }, nil
+}
+
+// Parses -repos, a comma separated list of repository names or owner/name
+func parseOnlyRepos(value string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if owner, repo, ok := strings.Cut(name, "/"); ok && (owner == "" || repo == "" || strings.Contains(repo, "/")) {
+			return nil, fmt.Errorf("repository must be a name or owner/name, got %q", name)
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
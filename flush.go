@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// flushPolicy is when a reporter writes out what it's buffered, besides when it's closed
+type flushPolicy struct {
+	// Flush after every finding
+	onFinding bool
+	// Flush this often (0 is never)
+	interval time.Duration
+}
+
+// flushingReporter flushes the reporter it wraps according to a policy
+//
+// Flushes on the interval come from another goroutine, so everything goes through the mutex.
+type flushingReporter struct {
+	mu     sync.Mutex
+	next   scan.Reporter
+	policy flushPolicy
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newFlushingReporter(next scan.Reporter, policy flushPolicy) *flushingReporter {
+	r := &flushingReporter{next: next, policy: policy}
+	if policy.interval > 0 {
+		r.stop = make(chan struct{})
+		r.done = make(chan struct{})
+		go r.run()
+	}
+
+	return r
+}
+
+func (r *flushingReporter) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.policy.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Flush(); err != nil {
+				logError("error", "flush findings", "", "", err)
+			}
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+func (r *flushingReporter) Report(f scan.Finding) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.next.Report(f); err != nil {
+		return err
+	}
+	if r.policy.onFinding {
+		return r.next.Flush()
+	}
+
+	return nil
+}
+
+func (r *flushingReporter) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.next.Flush()
+}
+
+func (r *flushingReporter) Close() error {
+	if r.stop != nil {
+		close(r.stop)
+		<-r.done
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.next.Close()
+}
+
+// Wraps a reporter factory so its reporters flush according to a policy
+func flushing(policy flushPolicy, factory reporterFactory) reporterFactory {
+	return func(w io.Writer) scan.Reporter {
+		return newFlushingReporter(factory(w), policy)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// flushRecorder is a reporter that counts the findings and flushes it gets
+type flushRecorder struct {
+	mu       sync.Mutex
+	reported int
+	flushes  int
+	closed   bool
+}
+
+func (r *flushRecorder) Report(f scan.Finding) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reported++
+	return nil
+}
+
+func (r *flushRecorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.flushes++
+	return nil
+}
+
+func (r *flushRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
+
+func (r *flushRecorder) counts() (int, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reported, r.flushes
+}
+
+func TestFlushPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy flushPolicy
+		want   int
+	}{
+		{name: "on close only", policy: flushPolicy{}, want: 0},
+		{name: "on every finding", policy: flushPolicy{onFinding: true}, want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &flushRecorder{}
+			r := newFlushingReporter(rec, tt.policy)
+			for i := 0; i < 3; i++ {
+				if err := r.Report(scan.Finding{Repo: "o/r"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if reported, flushes := rec.counts(); reported != 3 || flushes != tt.want {
+				t.Errorf("reported %d and flushed %d times, want 3 and %d", reported, flushes, tt.want)
+			}
+			if err := r.Close(); err != nil || !rec.closed {
+				t.Errorf("close = %v, closed %t, want the wrapped reporter closed", err, rec.closed)
+			}
+		})
+	}
+}
+
+func TestFlushInterval(t *testing.T) {
+	rec := &flushRecorder{}
+	r := newFlushingReporter(rec, flushPolicy{interval: 20 * time.Millisecond})
+	time.Sleep(150 * time.Millisecond)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, flushes := rec.counts()
+	if flushes < 2 {
+		t.Errorf("flushed %d times in 150ms with a 20ms interval, want several", flushes)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, after := rec.counts(); after != flushes {
+		t.Errorf("flushed %d more times after the reporter was closed", after-flushes)
+	}
+}
@@ -0,0 +1,37 @@
+// Package atomicfile writes files that only appear once they're complete
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// File is written to a temporary file that's renamed into place on Close, so a partial file is never visible
+type File struct {
+	*os.File
+	path string
+}
+
+// Creates the temporary file for path, next to it so the rename stays on the same filesystem
+func Create(path string) (*File, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	return &File{File: f, path: path}, nil
+}
+
+func (f *File) Close() error {
+	if err := f.File.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), f.path)
+}
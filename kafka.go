@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// How many findings the Kafka reporter holds while the brokers are unreachable before dropping them
+const kafkaQueueSize = 10000
+
+// How many times to try publishing a finding before giving up on it
+const kafkaAttempts = 5
+
+var errNoKafka = errors.New("this build doesn't support Kafka, rebuild with -tags kafka")
+
+var errKafkaQueueFull = errors.New("kafka queue is full, dropped finding")
+
+// messageProducer publishes keyed messages to a topic
+type messageProducer interface {
+	Produce(ctx context.Context, key, value []byte) error
+	Close() error
+}
+
+// Creates a producer for a topic, only set when built with the kafka tag
+var newKafkaProducer func(brokers []string, topic string) (messageProducer, error)
+
+// kafkaReporter publishes each finding as a JSON message keyed by the scan ID
+//
+// Findings are queued and published in the background, with retries, so an unreachable broker never stops
+// the scan.
+type kafkaReporter struct {
+	producer messageProducer
+	scanID   string
+	// How the waits between attempts are randomized
+	jitter scan.Jitter
+	queue  chan scan.Finding
+	done   chan struct{}
+}
+
+func newKafkaReporter(producer messageProducer, scanID string, jitter scan.Jitter) *kafkaReporter {
+	r := &kafkaReporter{
+		producer: producer,
+		scanID:   scanID,
+		jitter:   jitter,
+		queue:    make(chan scan.Finding, kafkaQueueSize),
+		done:     make(chan struct{}),
+	}
+	go r.run()
+
+	return r
+}
+
+func (r *kafkaReporter) run() {
+	defer close(r.done)
+
+	for f := range r.queue {
+		value, err := json.Marshal(newFindingRecord(f))
+		if err != nil {
+			logError("error", "publish finding", f.Account, f.Repo, err)
+			continue
+		}
+
+		for attempt := 1; ; attempt++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			err = r.producer.Produce(ctx, []byte(r.scanID), value)
+			cancel()
+			if err == nil || attempt == kafkaAttempts {
+				break
+			}
+			time.Sleep(r.jitter.Backoff(attempt, time.Second, 30*time.Second))
+		}
+		if err != nil {
+			logError("error", "publish finding", f.Account, f.Repo, err)
+		}
+	}
+}
+
+func (r *kafkaReporter) Report(f scan.Finding) error {
+	select {
+	case r.queue <- f:
+		return nil
+	default:
+		return errKafkaQueueFull
+	}
+}
+
+// Findings are published in the background as soon as they're queued, so there's nothing to flush
+func (r *kafkaReporter) Flush() error {
+	return nil
+}
+
+// Publishes everything still queued, then closes the producer
+func (r *kafkaReporter) Close() error {
+	close(r.queue)
+	<-r.done
+
+	return r.producer.Close()
+}
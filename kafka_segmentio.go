@@ -0,0 +1,35 @@
+//go:build kafka
+
+package main
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func init() {
+	newKafkaProducer = newSegmentioProducer
+}
+
+// segmentioProducer publishes with kafka-go, hashing keys so a scan's findings stay on one partition
+type segmentioProducer struct {
+	writer *kafka.Writer
+}
+
+func newSegmentioProducer(brokers []string, topic string) (messageProducer, error) {
+	return &segmentioProducer{writer: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireOne,
+	}}, nil
+}
+
+func (p *segmentioProducer) Produce(ctx context.Context, key, value []byte) error {
+	return p.writer.WriteMessages(ctx, kafka.Message{Key: key, Value: value})
+}
+
+func (p *segmentioProducer) Close() error {
+	return p.writer.Close()
+}
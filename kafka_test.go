@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// mockProducer keeps the messages published to it, failing the first fail attempts
+type mockProducer struct {
+	mu       sync.Mutex
+	fail     int
+	attempts int
+	keys     []string
+	values   [][]byte
+	closed   bool
+}
+
+func (p *mockProducer) Produce(ctx context.Context, key, value []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.attempts++
+	if p.attempts <= p.fail {
+		return errors.New("broker unreachable")
+	}
+	p.keys = append(p.keys, string(key))
+	p.values = append(p.values, value)
+
+	return nil
+}
+
+func (p *mockProducer) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestKafkaReporterPublishesFindings(t *testing.T) {
+	findings := []scan.Finding{
+		{Account: "acme", Repo: "docs", URL: "https://github.com/acme/docs/wiki", Result: scan.ProbeWriteable},
+		{Account: "acme", Repo: "handbook", URL: "https://github.com/acme/handbook/wiki", Result: scan.ProbeEmpty},
+	}
+
+	// The first attempt fails, so the reporter has to retry before anything is published
+	scanID := "20261014T120000Z-test"
+	producer := &mockProducer{fail: 1}
+	r := newKafkaReporter(producer, scanID, scan.JitterNone)
+	for _, f := range findings {
+		if err := r.Report(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !producer.closed {
+		t.Error("producer wasn't closed")
+	}
+	if len(producer.values) != len(findings) {
+		t.Fatalf("published %d messages, want %d", len(producer.values), len(findings))
+	}
+	for i, f := range findings {
+		if producer.keys[i] != scanID {
+			t.Errorf("message %d is keyed %q, want the scan ID %q", i, producer.keys[i], scanID)
+		}
+		var record findingRecord
+		if err := json.Unmarshal(producer.values[i], &record); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if record.Account != f.Account || record.Repo != f.Repo || record.URL != f.URL || record.Result != f.Result.String() {
+			t.Errorf("message %d is %+v, want %s/%s %s at %s", i, record, f.Account, f.Repo, f.Result, f.URL)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// listedRepo is a repository -list-only found, which a scan would probe
+type listedRepo struct {
+	Account  string `json:"account"`
+	Repo     string `json:"repo"`
+	URL      string `json:"repo_url"`
+	HasWiki  bool   `json:"has_wiki"`
+	Private  bool   `json:"private,omitempty"`
+	Archived bool   `json:"archived,omitempty"`
+	Fork     bool   `json:"fork,omitempty"`
+}
+
+// Writes listed repositories in one of the -format formats
+type listWriter func(w io.Writer, repos []listedRepo) error
+
+// Writers for -list-only, by -format
+var listFormats = map[string]listWriter{
+	"text":        writeTextListing,
+	"stable-text": writeStableTextListing,
+	"null":        writeNullListing,
+	"json":        writeJSONListing,
+	"ndjson":      writeJSONListing,
+	"csv":         writeCSVListing,
+	"table":       writeTableListing,
+}
+
+// Gets the -list-only writer for a format, which formats that only describe findings, like sarif, don't have
+func listWriterFor(format string) (listWriter, error) {
+	if write, ok := listFormats[format]; ok {
+		return write, nil
+	}
+	if format == "sarif" {
+		return nil, fmt.Errorf("-format sarif only has results for vulnerable wikis, so it can't be used with -list-only")
+	}
+
+	return nil, fmt.Errorf("-format %s only writes findings, so it can't be used with -list-only", format)
+}
+
+func writeTextListing(w io.Writer, repos []listedRepo) error {
+	bw := bufio.NewWriter(w)
+	for _, r := range repos {
+		label := "No wiki"
+		if r.HasWiki {
+			label = "Has wiki"
+		}
+		fmt.Fprintf(bw, "%s: %s, URL: %s\n", label, r.Repo, r.URL)
+	}
+
+	return bw.Flush()
+}
+
+func writeStableTextListing(w io.Writer, repos []listedRepo) error {
+	sorted := append([]listedRepo(nil), repos...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Account != sorted[j].Account {
+			return sorted[i].Account < sorted[j].Account
+		}
+		return sorted[i].Repo < sorted[j].Repo
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, r := range sorted {
+		fmt.Fprintf(bw, "%s\t%s\t%t\t%s\n", r.Account, r.Repo, r.HasWiki, r.URL)
+	}
+
+	return bw.Flush()
+}
+
+func writeNullListing(w io.Writer, repos []listedRepo) error {
+	bw := bufio.NewWriter(w)
+	for _, r := range repos {
+		bw.WriteString(r.URL)
+		bw.WriteByte(0)
+	}
+
+	return bw.Flush()
+}
+
+func writeJSONListing(w io.Writer, repos []listedRepo) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, r := range repos {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+func writeCSVListing(w io.Writer, repos []listedRepo) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"account", "repo", "repo_url", "has_wiki"})
+	for _, r := range repos {
+		cw.Write([]string{r.Account, r.Repo, r.URL, strconv.FormatBool(r.HasWiki)})
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func writeTableListing(w io.Writer, repos []listedRepo) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACCOUNT\tREPO\tWIKI\tURL")
+	for _, r := range repos {
+		wiki := "no"
+		if r.HasWiki {
+			wiki = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Account, r.Repo, wiki, r.URL)
+	}
+
+	return tw.Flush()
+}
+
+// Lists the repositories a scan of the targets would probe, after -skip-archived, -name-regex and the other
+// filters, without probing any of them
+//
+// Targets that can't be listed are left out, with an error once the rest are written.
+func listRepositories(ctx context.Context, run *scan.Run, accounts []string, w io.Writer, write listWriter) error {
+	var listed []listedRepo
+	failed := 0
+	for _, orgName := range accounts {
+		if ctx.Err() != nil {
+			break
+		}
+		if orgName == "" {
+			continue
+		}
+
+		repos, err := run.List(ctx, orgName)
+		if err != nil {
+			failed++
+			continue
+		}
+		for _, repo := range repos {
+			listed = append(listed, listedRepo{
+				Account:  orgName,
+				Repo:     repo.Name,
+				URL:      repo.URL,
+				HasWiki:  repo.HasWiki,
+				Private:  repo.Private,
+				Archived: repo.Archived,
+				Fork:     repo.Fork,
+			})
+		}
+	}
+
+	if err := write(w, listed); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d targets couldn't be listed", failed, len(accounts))
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var testListing = []listedRepo{
+	{Account: "org:b", Repo: "tools", URL: "https://github.com/b/tools", HasWiki: true},
+	{Account: "org:a", Repo: "site", URL: "https://github.com/a/site", Private: true},
+}
+
+func TestNDJSONListing(t *testing.T) {
+	write, ok := listFormats["ndjson"]
+	if !ok {
+		t.Fatal("no -list-only writer for ndjson")
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf, testListing); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(testListing) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(lines), len(testListing), buf.String())
+	}
+	for i, line := range lines {
+		var got listedRepo
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("line %d isn't JSON: %v", i+1, err)
+		}
+		if got != testListing[i] {
+			t.Errorf("line %d = %+v, want %+v", i+1, got, testListing[i])
+		}
+	}
+}
+
+func TestSARIFHasNoListing(t *testing.T) {
+	if _, err := listWriterFor("sarif"); err == nil || !strings.Contains(err.Error(), "-list-only") {
+		t.Fatalf("listWriterFor(sarif) error = %v, want one about -list-only", err)
+	}
+	if _, err := listWriterFor("json"); err != nil {
+		t.Fatalf("listWriterFor(json) error = %v", err)
+	}
+}
+
+func TestTableListing(t *testing.T) {
+	write, err := listWriterFor("table")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf, testListing); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "ACCOUNT  REPO   WIKI  URL\n" +
+		"org:b    tools  yes   https://github.com/b/tools\n" +
+		"org:a    site   no    https://github.com/a/site\n"
+	if buf.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
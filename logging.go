@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// The least severe level of diagnostics written, set with -log-level
+var logLevel = new(slog.LevelVar)
+
+// Diagnostics go to stderr, leaving stdout to the findings
+var logger = slog.New(slog.NewTextHandler(progress.around(os.Stderr), &slog.HandlerOptions{Level: logLevel}))
+
+// Log levels by name, as given to -log-level
+var logLevelNames = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// Sets the level from -log-level
+func setLogLevel(name string) error {
+	level, ok := logLevelNames[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("unknown log level %q, must be one of debug, info, warn, error", name)
+	}
+	logLevel.Set(level)
+
+	return nil
+}
+
+// Whether diagnostics at a level are written
+func logEnabled(level slog.Level) bool {
+	return logger.Enabled(context.Background(), level)
+}
+
+func logf(level slog.Level, format string, args ...any) {
+	if !logEnabled(level) {
+		return
+	}
+	logger.Log(context.Background(), level, fmt.Sprintf(format, args...))
+}
+
+func infof(format string, args ...any)  { logf(slog.LevelInfo, format, args...) }
+func warnf(format string, args ...any)  { logf(slog.LevelWarn, format, args...) }
+func errorf(format string, args ...any) { logf(slog.LevelError, format, args...) }
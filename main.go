@@ -1,137 +1,678 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"log"
-	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/offftherecord/gitwiki/internal/atomicfile"
+	"github.com/offftherecord/gitwiki/scan"
 )
 
-// Repository represents a Github repository
-type Repository struct {
-	Name    string `json:"name"`
-	URL     string `json:"html_url"`
-	HasWiki bool   `json:"has_wiki"`
+// Lists the targets that couldn't be scanned once the scan is done, as their errors were logged among everything
+// else, leaving out the ones that were only cut off
+func reportAccountErrors(accounts []string, errs []error) {
+	var failed []int
+	for i, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+			failed = append(failed, i)
+		}
+	}
+	if len(failed) == 0 {
+		return
+	}
+
+	errorf("%d of %d targets couldn't be scanned", len(failed), len(accounts))
+	for _, i := range failed {
+		errorf("Couldn't scan %s: %v", accounts[i], errs[i])
+	}
 }
 
-// Gets an HTTP client	that doesn't follow redirects
-func getClient() *http.Client {
-	client := &http.Client{
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
+// Writes the hosts with vulnerable wikis to a path, or to stderr for "-"
+func writeVulnerableHosts(summary *scan.Summary, path string) error {
+	if path == "-" {
+		return summary.WriteHosts(os.Stderr, false)
+	}
+
+	f, err := atomicfile.Create(path)
+	if err != nil {
+		return err
 	}
+	if err := summary.WriteHosts(f, strings.HasSuffix(path, ".json")); err != nil {
+		f.File.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	return f.Close()
+}
 
-	return client
+// Main function
+func main() {
+	os.Exit(runMain())
 }
 
-// Checks if a repository has a wiki and if it's writable
-func checkWiki(repo Repository) {
-	if repo.HasWiki {
-		url := repo.URL + "/wiki"
+// Parses the flags and runs the scan, returning the exit code once every output has been closed
+func runMain() int {
+	scanner := scan.NewScanner()
+	scanner.UserAgent = "gitwiki/" + buildVersion()
 
-		client := getClient()
-		resp, err := client.Get(url)
+	var wikiURLs []string
+	flag.Func("url", "probe the wiki at `url` directly without using the API, repeatable", func(value string) error {
+		wikiURLs = append(wikiURLs, value)
+		return nil
+	})
+	var repoNames []string
+	flag.Func("repo", "check the single repository `owner/repo`, or its URL, without listing its account, repeatable", func(value string) error {
+		repoNames = append(repoNames, value)
+		return nil
+	})
+	flag.StringVar(&appID, "app-id", "", "authenticate as the GitHub App with this `id`, along with -app-installation-id and -app-private-key (default $GITHUB_APP_ID)")
+	flag.Int64Var(&appInstallationID, "app-installation-id", 0, "`id` of the GitHub App's installation to get tokens for (default $GITHUB_APP_INSTALLATION_ID)")
+	flag.StringVar(&appPrivateKeyFile, "app-private-key", "", "GitHub App private key PEM `file` (default $GITHUB_APP_PRIVATE_KEY_FILE)")
+	var tokenFlags []string
+	flag.Func("token", "GitHub `token` to use alongside GITHUB_TOKEN and GITHUB_TOKENS, repeatable", func(value string) error {
+		tokenFlags = append(tokenFlags, value)
+		return nil
+	})
+	inputFile := flag.String("input-file", "", "read targets from `path`, one per line, skipping blank lines and # comments")
+	targetsRepo := flag.String("targets-repo", "", "read targets from a file in a GitHub repository, given as `owner/repo:path`")
+	flag.Func("repos", "look up just these comma separated `names` (or owner/name) in each account instead of listing it", func(value string) error {
+		names, err := parseOnlyRepos(value)
+		scanner.OnlyRepos = append(scanner.OnlyRepos, names...)
+		return err
+	})
+	checkpointFile := flag.String("checkpoint-file", "", "record checked repositories in `path` as the scan goes, and skip them when an interrupted scan is run again")
+	metricsAddr := flag.String("metrics-addr", "", "serve Prometheus metrics at /metrics on `address`, such as :9090")
+	showVersion := flag.Bool("version", false, "print the version, commit and build date of gitwiki, then exit")
+	skipTokenCheck := flag.Bool("skip-token-check", false, "don't check the tokens against the API before scanning")
+	listOnly := flag.Bool("list-only", false, "list the repositories a scan would probe, after any filters, then exit without probing")
+	estimate := flag.Bool("estimate", false, "estimate the API calls and wiki probes a scan would take, then exit without probing")
+	flag.Var(headerFlag(scanner.APIHeaders), "api-header", "extra `header` (\"Key: Value\") to send on every API request, repeatable")
+	flag.BoolVar(&scanner.BrowserHeaders, "browser-headers", false, "send realistic browser headers on wiki probes, for hosts behind WAFs that block non-browser requests")
+	inventoryOut := flag.String("inventory-out", "", "write every checked repository and how it was classified to `path` as JSON lines")
+	format := flag.String("format", "text", "output `format`: "+strings.Join(outputFormatList(), ", "))
+	show := flag.String("show", "vulnerable", "comma separated `results` to report: vulnerable, all, or any of "+strings.Join(probeResultList(), ", "))
+	stateFile := flag.String("state-file", "", "remember when each repository was pushed to and how it was classified in `path`")
+	incremental := flag.Bool("incremental", false, "skip repositories that haven't been pushed to since they were last checked, needs -state-file")
+	flag.Func("log-level", "write diagnostics at `level` and above to stderr: debug, info, warn or error (default info)", setLogLevel)
+	flag.BoolVar(&errorsJSON, "errors-json", false, "write operational errors to stderr as JSON lines")
+	flag.Func("proxy", "send API requests and wiki probes through the proxy at `url` (http, https or socks5) instead of HTTPS_PROXY", setDefaultProxy)
+	flag.Var(proxyRuleFlag{}, "proxy-for", "send requests for hosts matching `pattern=proxy` through proxy (or \"direct\"), repeatable")
+	proxyMap := flag.String("proxy-map", "", "read -proxy-for rules from `file`, one per line")
+	flag.IntVar(&scanner.TraceRedirects, "trace-redirects", 0, "follow up to `n` redirects from a probe and report the chain, without changing how it's classified")
+	outputRPS := flag.Float64("output-rps", 0, "emit at most `n` findings a second on stdout, queueing bursts (0 is unlimited)")
+	flag.BoolVar(&scanner.AllowPartial, "allow-partial", false, "when listing an account fails partway, scan the repositories listed so far instead of stopping")
+	flag.BoolVar(&scanner.SkipArchived, "skip-archived", false, "don't probe the wikis of archived repositories")
+	flag.BoolVar(&scanner.SkipForks, "skip-forks", false, "don't probe the wikis of forks")
+	flag.Func("pushed-since", "only probe repositories pushed to since `time`, an RFC 3339 time or a duration ago such as 24h", func(value string) error {
+		t, err := parsePushedSince(value)
+		scanner.PushedSince = t
+		return err
+	})
+	flag.Func("rules", "flag wikis whose landing page matches any of the named regexps in `file`, a YAML mapping of names to patterns", func(path string) error {
+		rules, err := scan.LoadRules(path)
+		scanner.Rules = append(scanner.Rules, rules...)
+		return err
+	})
+	flag.Func("name-regex", "only probe repositories whose name matches `regexp`", func(value string) error {
+		filter, err := parseNameFilter(value)
 		if err != nil {
-			log.Println(err)
-			return
+			return err
 		}
-		defer resp.Body.Close()
+		scanner.Filters = append(scanner.Filters, filter)
+		return nil
+	})
+	flag.Func("first-page-marker", "also count landing pages with `text` as empty wikis, for GitHub in other languages, repeatable", func(value string) error {
+		if value == "" {
+			return errors.New("marker can't be empty")
+		}
+		scanner.FirstPageMarkers = append(scanner.FirstPageMarkers, value)
+		return nil
+	})
+	flag.StringVar(&scanner.TestPage, "test-page", "", "ask for the wiki page `name` in the writeable probe instead of a random one")
+	flag.BoolVar(&scanner.VerifyEmpty, "verify-empty", false, "check wikis that look empty against their git repository, which only exists once there's a page")
+	flag.DurationVar(&scanner.RequestTimeout, "request-timeout", scanner.RequestTimeout, "give up on a single wiki probe after `duration`, body included (0 is no limit)")
+	flag.StringVar(&scanner.APICacheDir, "cache-dir", "", "cache API responses in `dir` and only fetch them again when they've changed")
+	quiet := flag.Bool("quiet", false, "don't show which repository is being checked on stderr")
+	noFail := flag.Bool("no-fail", false, "exit 0 even when vulnerable wikis are found, only failing on errors")
+	flag.BoolVar(&scanner.AllowDuplicates, "allow-duplicates", false, "check and report a repository again each time it comes up, instead of once per run")
+	retryFailed := flag.Bool("retry-failed", false, "probe repositories that errored once more at the end of the scan")
+	flag.IntVar(&scanner.SearchMax, "search-max", scanner.SearchMax, "scan at most `n` repositories per search: input (the search API stops at 1000)")
+	flag.DurationVar(&scanner.Delay, "delay", 0, "wait `duration` between requests, shared by every worker so it caps the rate of probes and listing pages")
+	flag.DurationVar(&scanner.DelayJitter, "delay-jitter", 0, "add a random wait of up to `duration` to each -delay")
+	flag.Func("backoff-jitter", "how to randomize retry delays: full, equal or none (default full)", func(value string) error {
+		jitter, err := scan.ParseJitter(value)
+		scanner.BackoffJitter = jitter
+		return err
+	})
+	flag.BoolVar(&scanner.FirstPageOnly, "firstpage-only", false, "classify wikis from their landing page alone, skipping the writeable probe")
+	otelEndpoint := flag.String("otel-endpoint", "", "export OpenTelemetry traces to the OTLP/HTTP `url` (needs a build with -tags otel)")
+	flag.IntVar(&scanner.MinBodySize, "min-body-size", scanner.MinBodySize, "skip wikis whose landing page is smaller than `bytes`, as it's likely not a real wiki page")
+	flag.BoolVar(&scanner.WikiGitLog, "wiki-git-log", false, "fetch the last commit of writeable wikis to report who last edited them and when (needs git)")
+	flag.BoolVar(&scanner.NoFirstPage, "no-firstpage", false, "don't trust the empty wiki marker on landing pages and rely on the writeable probe alone")
+	flag.BoolVar(&scanner.SkipOrgDisabled, "skip-org-disabled", false, "stop probing an account's wikis once they look disabled account-wide")
+	summary := flag.Bool("summary", true, "write a summary of how repositories were classified to stderr at the end")
+	noSummary := flag.Bool("no-summary", false, "don't write the summary, same as -summary=false")
+	findingFilesDir := flag.String("finding-files-dir", "", "also write each finding as its own JSON file in `dir`, named by its fingerprint")
+	findingFilesMax := flag.Int("finding-files-max", defaultFindingFilesMax, "most `files` to write to -finding-files-dir")
+	s3Dest := flag.String("s3", "", "upload the findings to `s3://bucket/prefix` at the end of the scan (needs a build with -tags s3)")
+	warm := flag.Int("warm-connections", 0, "open `n` keep-alive connections to the wiki host before the scan and keep that many idle for reuse")
+	flag.StringVar(&scanner.UserAgent, "user-agent", scanner.UserAgent, "`User-Agent` to send on API requests and wiki probes, unless -browser-headers picks one")
+	output := flag.String("output", "", "write findings to `path` instead of stdout, creating its directory if needed")
+	flag.Func("compress", "compress -output and -output-dir files: gzip or none (default gzip for an -output ending in .gz)", setCompression)
+	webhookURL := flag.String("webhook-url", "", "post each finding as JSON to the webhook at `url`, such as a Slack incoming webhook")
+	kafkaBrokers := flag.String("kafka-brokers", "", "publish findings as JSON to Kafka at these comma separated `brokers` (needs a build with -tags kafka)")
+	kafkaTopic := flag.String("kafka-topic", "", "Kafka `topic` to publish findings to")
+	flag.IntVar(&scanner.MaxBodyBytes, "max-body-bytes", scanner.MaxBodyBytes, "read at most `bytes` of each wiki landing page (0 is no limit)")
+	flag.IntVar(&scanner.RangeBytes, "range-bytes", 0, "only download the first `bytes` of wiki landing pages, using a Range header")
+	flag.BoolVar(&scanner.StrictURLs, "strict-urls", false, "refuse to probe repository URLs that aren't https owner/repo URLs on a -provider-host")
+	flag.Func("provider-host", "comma separated `hosts` repository URLs may point at with -strict-urls (default github.com)", func(value string) error {
+		scanner.ProviderHosts = strings.Split(value, ",")
+		return nil
+	})
+	flag.Func("stages", "comma separated detection `stages` to run, in order (default "+strings.Join(scan.StageNames(), ",")+")", func(value string) error {
+		stages, err := scan.ParseStages(value)
+		if err != nil {
+			return err
+		}
+		scanner.Stages = stages
+		return nil
+	})
+	mode := flag.String("mode", "html", "how wikis are checked: html, going by their pages, or git, going by their git repositories where it can")
+	flag.Func("min-severity", "only report vulnerable wikis of at least `severity`: medium or high", setMinSeverity)
+	flag.Func("null-field", "finding `field` written by -format null: url, repo, account or result (default url)", func(value string) error {
+		if _, ok := nullFields[value]; !ok {
+			return fmt.Errorf("unknown field %q", value)
+		}
+		nullField = value
+		return nil
+	})
+	caCertFile := flag.String("ca-cert", "", "also trust the CA certificates in PEM `file`, such as an Enterprise Server's private CA")
+	insecureSkipVerify := flag.Bool("insecure-skip-verify", false, "don't check TLS certificates at all, only for testing")
+	githubURL := flag.String("github-url", "", "scan the GitHub Enterprise Server at `url` instead of github.com (default $GITHUB_BASE_URL)")
+	flag.IntVar(&scanner.ProbeRetries, "probe-retries", scanner.ProbeRetries, "retry probes up to `n` times after timeouts, connection errors and 5xx responses")
+	flag.IntVar(&scanner.Concurrency, "concurrency", scanner.Concurrency, "probe up to `n` wikis at once")
+	accountConcurrency := flag.Int("account-concurrency", 1, "scan up to `n` accounts at once, sharing -concurrency between them")
+	timeout := flag.Duration("timeout", 0, "stop the whole scan after `duration`, writing out what was found and exiting with status 2 (1 with findings)")
+	flag.DurationVar(&scanner.AccountTimeout, "per-account-timeout", 0, "stop scanning an account after `duration`, listing included, and move on to the next one")
+	flag.BoolVar(&scanner.AnonymousProbes, "anonymous-probes", false, "probe wikis logged out, without the token, to see what anyone without access sees")
+	flag.Func("repo-visibility", "scan `visibility` repositories: public, private (including internal) or all, private needing a token that can see them (default public)", func(value string) error {
+		visibility, err := parseVisibility(value)
+		if err != nil {
+			return err
+		}
+		scanner.Visibility = visibility
+		return nil
+	})
+	flag.BoolFunc("include-private", "also scan the private repositories GITHUB_TOKEN can see, same as -repo-visibility all", func(value string) error {
+		include, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		if include {
+			scanner.Visibility = scan.VisibilityAll
+		} else if scanner.Visibility == scan.VisibilityAll {
+			scanner.Visibility = scan.VisibilityPublic
+		}
+		return nil
+	})
+	flag.IntVar(&scanner.MaxRepos, "max-repos", 0, "list at most `n` repositories for each account, the most recently pushed to (0 is no limit)")
+	reportAll := flag.Bool("report-all", false, "also report wikis that are locked down or disabled, for a full picture of wiki posture")
+	flag.BoolVar(&scanner.ReportReadable, "report-readable", false, "report wikis with pages that can be read but not edited as readable")
+	flag.BoolVar(&scanner.FixAccountType, "fix-account-type", false, "when an org: or user: account's repositories aren't found, look up its actual type and list it as that")
+	flushOnFinding := flag.Bool("flush-on-finding", false, "flush findings files after every finding, not just stdout")
+	flushInterval := flag.Duration("flush-interval", 0, "also flush every output at least every `duration`")
+	manifestOut := flag.String("manifest", "", "write a JSON manifest of every account scanned and how much of it was covered to `path` at the end")
+	hostsOut := flag.String("vulnerable-hosts", "", "write the hosts with vulnerable wikis and their counts to `path` at the end (- for stderr, JSON for a .json path)")
+	outputDir := flag.String("output-dir", "", "also write each account's findings to its own file in `dir`")
+	flag.Parse()
 
-		if resp.StatusCode == http.StatusOK {
-			// Check if wiki is writable but doesn't have a first page yet
-			fmt.Printf("Readable: %s, URL: %s\n", repo.Name, url)
+	if *showVersion {
+		writeVersion(os.Stdout)
+		return 0
+	}
 
-			body, err := io.ReadAll(resp.Body)
-			if err != nil {
-				log.Println("Error reading response body:", err)
-				return
-			}
-			bodyStr := string(body)
-
-			// Check if wiki is writable but doesn't have a first page yet
-			if strings.Contains(bodyStr, "Create the first page") {
-				fmt.Printf("Writable-Firstpage: %s, URL: %s\n", repo.Name, url)
-			} else {
-
-				// If we can go to github.com/<repo>/wiki/<somenewpage> that means we can edit an existing wiki
-				url = url + "/notrealpage"
-
-				client := getClient()
-				resp, err := client.Get(url)
-				if err != nil {
-					fmt.Println(err)
-					return
-				}
-				defer resp.Body.Close()
-
-				if resp.StatusCode == http.StatusOK {
-					fmt.Printf("Writable: %s, URL: %s\n", repo.Name, url)
-				}
+	shown, err := parseShow(*show)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if *reportAll {
+		scanner.ReportReadable = true
+		for _, r := range scan.ProbeResults() {
+			if r.Vulnerable() || r.Safe() || r == scan.ProbeDisabled || r == scan.ProbeOrgDisabled || r == scan.ProbeAdminDisabled {
+				shown[r] = true
 			}
 		}
 	}
-}
+	if scanner.ReportReadable {
+		shown[scan.ProbeReadable] = true
+	}
 
-// Gets all repositories for a given organization
-func getRepositories(orgName string) ([]Repository, error) {
-	// We use /users/ and not /orgs/ because not all Github repositories belong to orgs, but all orgs are users apparently
-	url := fmt.Sprintf("https://api.github.com/users/%s/repos", orgName)
+	// Diagnostics and operational errors go where the rest of gitwiki's do
+	scanner.Logger = logger
+	scanner.OnError = logError
+	scanner.Proxy = proxyForRequest
 
-	client := getClient()
+	scanner.AddTokens(os.Getenv("GITHUB_TOKEN"))
+	scanner.AddTokens(strings.Split(os.Getenv("GITHUB_TOKENS"), ",")...)
+	scanner.AddTokens(tokenFlags...)
 
-	resp, err := client.Get(url)
+	if *githubURL == "" {
+		*githubURL = os.Getenv("GITHUB_BASE_URL")
+	}
+	if *githubURL != "" {
+		if err := scanner.SetGitHubURL(*githubURL); err != nil {
+			fatalf("invalid GitHub URL %q: %v", *githubURL, err)
+		}
+	}
+	if err := scanner.ConfigureTLS(*caCertFile, *insecureSkipVerify); err != nil {
+		fatalf("loading -ca-cert: %v", err)
+	}
+	if *insecureSkipVerify {
+		warnf("-insecure-skip-verify is set: TLS certificates aren't checked, so anyone on the network can read and change requests, tokens included")
+	}
+	app, err := loadAppAuth()
 	if err != nil {
-		return nil, err
+		fatalf("%v", err)
+	}
+	if app != nil {
+		if err := scanner.AuthenticateApp(context.Background(), *app); err != nil {
+			fatalf("authenticating as GitHub App %s: %v", app.ID, err)
+		}
+	}
+
+	if scanner.Concurrency < 1 {
+		fatalf("-concurrency must be at least 1")
+	}
+	if *accountConcurrency < 1 {
+		fatalf("-account-concurrency must be at least 1")
+	}
+	if scanner.MaxRepos < 0 {
+		fatalf("-max-repos can't be negative")
+	}
+	if scanner.Delay < 0 || scanner.DelayJitter < 0 {
+		fatalf("-delay and -delay-jitter can't be negative")
+	}
+	switch *mode {
+	case "html":
+	case "git":
+		scanner.Stages = scan.WithGitStage(scanner.Stages)
+	default:
+		fatalf("unknown mode %q, must be html or git", *mode)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch repositories: %s", resp.Status)
+	if scanner.APICacheDir != "" {
+		if err := os.MkdirAll(scanner.APICacheDir, 0o755); err != nil {
+			fatalf("creating cache directory: %v", err)
+		}
 	}
 
-	var repos []Repository
-	if err := json.NewDecoder(resp.Body).Decode(&repos); err != nil {
-		return nil, err
+	if *metricsAddr != "" {
+		scanner.Metrics = scan.NewMetrics()
+		if err := serveMetrics(*metricsAddr, scanner.Metrics); err != nil {
+			fatalf("serving metrics: %v", err)
+		}
 	}
 
-	return repos, nil
-}
+	if !*skipTokenCheck {
+		if err := scanner.CheckTokens(context.Background()); err != nil {
+			fatalf("%v", err)
+		}
+	}
 
-// Scans an organization for repositories with wikis
-func scanOrg(orgName string) {
-	if orgName == "" {
-		fmt.Println("Organization name cannot be empty")
-		return
+	if scanner.RangeBytes > 0 && scanner.RangeBytes < scanner.MinBodySize {
+		fatalf("-range-bytes must be at least -min-body-size")
 	}
-	repos, err := getRepositories(orgName)
-	if err != nil {
-		log.Fatalln("Error:", err)
+	if scanner.MaxBodyBytes < 0 || scanner.MaxBodyBytes > 0 && scanner.MaxBodyBytes < scanner.MinBodySize {
+		fatalf("-max-body-bytes must be 0 or at least -min-body-size")
 	}
 
-	for _, repo := range repos {
-		checkWiki(repo)
+	if scanner.NoFirstPage && scanner.FirstPageOnly {
+		fatalf("-no-firstpage and -firstpage-only can't be used together")
 	}
-}
 
-// Main function
-func main() {
+	outFormat, ok := outputFormats[*format]
+	if !ok {
+		fatalf("unknown format %q, must be one of %s", *format, strings.Join(outputFormatList(), ", "))
+	}
+	var listWrite listWriter
+	if *listOnly {
+		if listWrite, err = listWriterFor(*format); err != nil {
+			fatalf("%v", err)
+		}
+	}
+
+	if *proxyMap != "" {
+		if err := loadProxyMap(*proxyMap); err != nil {
+			fatalf("loading proxy map: %v", err)
+		}
+	}
+
+	var accounts []string
+	for _, u := range wikiURLs {
+		accounts = append(accounts, "url:"+u)
+	}
+	for _, name := range repoNames {
+		accounts = append(accounts, "repo:"+name)
+	}
+	if *targetsRepo != "" {
+		targets, err := scanner.TargetsFromRepo(context.Background(), *targetsRepo)
+		if err != nil {
+			fatalf("reading targets repo: %v", err)
+		}
+		accounts = append(accounts, targets...)
+	}
+
+	if *inputFile != "" {
+		f, err := os.Open(*inputFile)
+		if err != nil {
+			fatalf("reading input file: %v", err)
+		}
+		targets, err := scan.ParseTargets(f)
+		f.Close()
+		if err != nil {
+			fatalf("reading input file: %v", err)
+		}
+		accounts = append(accounts, targets...)
+	}
+
+	// Targets given with flags replace stdin
+	if flag.NArg() > 0 {
+		accounts = append(accounts, flag.Args()...)
+	} else if len(accounts) == 0 {
+		targets, err := scan.ParseTargets(os.Stdin)
+		if err != nil {
+			fatalf("reading from stdin: %v", err)
+		}
+		accounts = append(accounts, targets...)
+	}
+
+	if *estimate {
+		if err := estimateScan(context.Background(), scanner, accounts, os.Stdout); err != nil {
+			fatalf("%v", err)
+		}
+		return 0
+	}
 
-	if len(os.Args) > 1 {
-		orgName := os.Args[1]
-		scanOrg(orgName)
-	} else {
-		scanner := bufio.NewScanner(os.Stdin)
-		for scanner.Scan() {
-			orgName := strings.TrimSpace(scanner.Text())
-			scanOrg(orgName)
+	if *listOnly {
+		if err := listRepositories(context.Background(), scanner.NewRun(), accounts, os.Stdout, listWrite); err != nil {
+			fatalf("%v", err)
 		}
-		if err := scanner.Err(); err != nil {
-			log.Fatalln("Error reading from stdin:", err)
+		return 0
+	}
+
+	var store *scan.Store
+	if *stateFile != "" {
+		store, err = scan.LoadStore(*stateFile, *incremental)
+		if err != nil {
+			fatalf("loading state: %v", err)
+		}
+	} else if *incremental {
+		fatalf("-incremental needs -state-file")
+	}
+
+	var checkpoint *scan.Checkpoint
+	if *checkpointFile != "" {
+		checkpoint, err = scan.OpenCheckpoint(*checkpointFile)
+		if err != nil {
+			fatalf("opening checkpoint: %v", err)
+		}
+		if n := checkpoint.Len(); n > 0 {
+			infof("Resuming from %s, skipping the %d repositories already checked", *checkpointFile, n)
+		}
+	}
+
+	var inventory *scan.Inventory
+	if *inventoryOut != "" {
+		f, err := os.Create(*inventoryOut)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		defer f.Close()
+		inventory = scan.NewInventory(f)
+	}
+
+	// Stdout is written a finding at a time as before, files are left to buffer unless asked otherwise
+	stdoutFlush := flushPolicy{onFinding: true, interval: *flushInterval}
+	fileFlush := flushPolicy{onFinding: *flushOnFinding, interval: *flushInterval}
+	factory := showing(shown, flushing(fileFlush, outFormat.factory))
+	// Findings on the same terminal as the progress line have it erased first
+	progress.enabled = !*quiet && isTerminal(os.Stderr)
+	if progress.enabled {
+		scanner.Progress = progress.checking
+	}
+	var stdoutWriter io.Writer = os.Stdout
+	if *output != "" {
+		// Only truncated once everything else checks out, so a bad flag doesn't lose the last run's findings
+		if err := os.MkdirAll(filepath.Dir(*output), 0o755); err != nil {
+			fatalf("creating output directory: %v", err)
+		}
+		f, err := os.Create(*output)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		// Closed after the findings, including when the scan is interrupted, so a gzip trailer is always written
+		var out io.WriteCloser = f
+		if compressing(*output) {
+			out = newGzipFile(f)
+		}
+		defer func() {
+			if err := out.Close(); err != nil {
+				logError("error", "write output", "", "", err)
+			}
+		}()
+		stdoutWriter = out
+	} else if progress.enabled && isTerminal(os.Stdout) {
+		stdoutWriter = progress.around(os.Stdout)
+	}
+	stdout := showing(shown, pacing(*outputRPS, flushing(stdoutFlush, outFormat.factory)))(stdoutWriter)
+	defer stdout.Close()
+
+	if *kafkaBrokers != "" {
+		if newKafkaProducer == nil {
+			fatalf("%v", errNoKafka)
+		}
+		if *kafkaTopic == "" {
+			fatalf("-kafka-brokers needs -kafka-topic")
+		}
+
+		producer, err := newKafkaProducer(strings.Split(*kafkaBrokers, ","), *kafkaTopic)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		kafka := &showReporter{Reporter: newKafkaReporter(producer, scanner.ScanID, scanner.BackoffJitter), shown: shown}
+		defer func() {
+			if err := kafka.Close(); err != nil {
+				logError("error", "close kafka producer", "", "", err)
+			}
+		}()
+		stdout = multiReporter{stdout, kafka}
+	}
+
+	if *webhookURL != "" {
+		webhook := &showReporter{Reporter: newWebhookReporter(*webhookURL, scanner.NewClient(), scanner.BackoffJitter), shown: shown}
+		defer func() {
+			if err := webhook.Close(); err != nil {
+				logError("error", "close webhook", "", "", err)
+			}
+		}()
+		stdout = multiReporter{stdout, webhook}
+	}
+
+	if *findingFilesDir != "" {
+		files, err := newFindingFilesReporter(*findingFilesDir, *findingFilesMax)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		stdout = multiReporter{stdout, &showReporter{Reporter: files, shown: shown}}
+	}
+
+	var upload *s3Upload
+	if *s3Dest != "" {
+		upload, err = newS3Upload(*s3Dest, scanner.ScanID, outFormat.ext, factory)
+		if err != nil {
+			fatalf("%v", err)
 		}
+		stdout = multiReporter{stdout, upload}
+	}
+
+	// Counts what decides the exit status, whichever of the findings are shown
+	found := &foundCounter{}
+	stdout = multiReporter{stdout, found}
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	// The first interrupt stops the scan and still writes everything out, a second one exits straight away
+	ctx, stopSignals := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	go func() {
+		<-ctx.Done()
+		stopSignals()
+	}()
+	if *warm > 0 {
+		warmed := scanner.WarmConnections(ctx, *warm)
+		infof("Warmed %d of %d connections to %s", warmed, *warm, scanner.ProbeHost)
+	}
+	if *otelEndpoint != "" {
+		if err := enableTracing(*otelEndpoint); err != nil {
+			fatalf("%v", err)
+		}
+		scanner.Tracer = tracer
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := tracer.Shutdown(shutdownCtx); err != nil {
+				logError("error", "export traces", "", "", err)
+			}
+		}()
+	}
+
+	run := scanner.NewRun()
+	run.Inventory = inventory
+	run.Store = store
+	run.Manifest = scan.NewManifest(scanner.ScanID, *manifestOut != "")
+	run.RetryFailed = *retryFailed
+	run.Checkpoint = checkpoint
+
+	// Account files stay open for the retry pass, which can still add to them
+	var accountReporters []scan.Reporter
+	var accountReportersMu sync.Mutex
+	scanAccount := func(orgName string) error {
+		if *outputDir == "" || orgName == "" {
+			return run.Scan(ctx, orgName, stdout)
+		}
+
+		// Without its own file the account's findings still go to the shared output, so the scan carries on and
+		// shuts down as usual
+		accountReporter, err := newAccountReporter(*outputDir, orgName, outFormat.ext, factory)
+		if err != nil {
+			logError("error", "create findings file", orgName, "", err)
+			return run.Scan(ctx, orgName, stdout)
+		}
+		scanErr := run.Scan(ctx, orgName, multiReporter{stdout, accountReporter})
+		if run.RetryFailed {
+			accountReportersMu.Lock()
+			accountReporters = append(accountReporters, accountReporter)
+			accountReportersMu.Unlock()
+		} else if err := accountReporter.Close(); err != nil {
+			logError("error", "write findings file", orgName, "", err)
+		}
+
+		return scanErr
+	}
+
+	// Accounts are handed out in order to -account-concurrency workers, one at a time each, and why any of them
+	// couldn't be scanned is kept by their place in the list
+	accountJobs := make(chan int)
+	accountErrs := make([]error, len(accounts))
+	var accountWorkers sync.WaitGroup
+	for n := 0; n < *accountConcurrency; n++ {
+		accountWorkers.Add(1)
+		go func() {
+			defer accountWorkers.Done()
+			for i := range accountJobs {
+				accountErrs[i] = scanAccount(accounts[i])
+			}
+		}()
+	}
+	for i := range accounts {
+		// Checked first too, as select picks at random when a worker is free as well
+		if ctx.Err() == nil {
+			select {
+			case accountJobs <- i:
+				continue
+			case <-ctx.Done():
+			}
+		}
+		warnf("Scan stopped, %d of %d targets weren't scanned", len(accounts)-i, len(accounts))
+		break
+	}
+	close(accountJobs)
+	accountWorkers.Wait()
+
+	stopped := ctx.Err() != nil
+	if !stopped {
+		run.RetryFailures(ctx)
+	}
+	progress.clear()
+	if err := run.Checkpoint.Close(!stopped); err != nil {
+		logError("error", "close checkpoint", "", "", err)
+	}
+	if upload != nil {
+		if err := upload.Close(); err != nil {
+			logError("error", "upload findings", "", "", err)
+		}
+	}
+	if *summary && !*noSummary {
+		run.Summary.Write(os.Stderr)
+	}
+	if *manifestOut != "" {
+		if err := run.Manifest.Write(*manifestOut); err != nil {
+			logError("error", "write manifest", "", "", err)
+		}
+	}
+	if *hostsOut != "" {
+		if err := writeVulnerableHosts(run.Summary, *hostsOut); err != nil {
+			logError("error", "write vulnerable hosts", "", "", err)
+		}
+	}
+	for _, accountReporter := range accountReporters {
+		if err := accountReporter.Close(); err != nil {
+			logError("error", "write findings file", "", "", err)
+		}
+	}
+	reportAccountErrors(accounts, accountErrs)
+
+	switch {
+	case stopped && !errors.Is(ctx.Err(), context.DeadlineExceeded):
+		warnf("Interrupted, results are partial")
+		return exitInterrupted
+	case stopped:
+		warnf("-timeout of %s reached, results are partial", *timeout)
+	}
+
+	switch {
+	case !*noFail && found.n.Load() > 0:
+		return exitFound
+	case stopped || errorsLogged.Load():
+		return exitError
+	default:
+		return 0
 	}
 }
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// Landing page of a wiki with content, long enough to be classified
+var populatedWikiBody = strings.Repeat("<p>Welcome to the wiki</p>\n", 40)
+
+// Serves a fixed response
+func respond(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+// Set in the environment of a test binary run as gitwiki itself, holding its arguments as a JSON array
+const runMainEnv = "GITWIKI_TEST_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if value, ok := os.LookupEnv(runMainEnv); ok {
+		var args []string
+		if err := json.Unmarshal([]byte(value), &args); err != nil {
+			panic(err)
+		}
+		os.Args = append([]string{"gitwiki"}, args...)
+		os.Exit(runMain())
+	}
+
+	os.Exit(m.Run())
+}
+
+// Runs gitwiki with args in a process of its own, as runMain registers its flags and may exit, getting its
+// exit status
+func runGitwiki(t *testing.T, args ...string) int {
+	t.Helper()
+
+	status, _, _ := runGitwikiWithInput(t, "", args...)
+	return status
+}
+
+// Runs gitwiki with args and stdin, getting its exit status and what it wrote to stdout and stderr
+func runGitwikiWithInput(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), runMainEnv+"="+string(encoded), "GITHUB_TOKEN=", "GITHUB_TOKENS=")
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr strings.Builder
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err = cmd.Run()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0, stdout.String(), stderr.String()
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode(), stdout.String(), stderr.String()
+	default:
+		t.Fatal(err)
+		return -1, "", ""
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	mux := http.NewServeMux()
+	// Writeable: the landing page has content and any page opens
+	open := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(populatedWikiBody))
+	}
+	mux.HandleFunc("/open/r/wiki", open)
+	mux.HandleFunc("/open/r/wiki/", open)
+	// Locked down: pages need a login
+	mux.HandleFunc("/locked/r/wiki", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(populatedWikiBody))
+	})
+	mux.Handle("/locked/r/wiki/", http.RedirectHandler("/login", http.StatusFound))
+	mux.HandleFunc("/broken/r/wiki", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	// Firstpage: the wiki offers to create its first page, a medium severity finding
+	mux.HandleFunc("/empty/r/wiki", respond(http.StatusOK, strings.Repeat("<p>Nothing here</p>\n", 40)+scan.WikiFirstPageMarker))
+	mux.HandleFunc("/slow/r/wiki", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	target := func(owner string) string { return "url:" + srv.URL + "/" + owner + "/r" }
+	base := []string{"-skip-token-check", "-quiet", "-summary=false", "-probe-retries", "0", "-output", os.DevNull}
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "nothing found", args: []string{target("locked")}, want: 0},
+		{name: "found", args: []string{target("open")}, want: exitFound},
+		{name: "no-fail", args: []string{"-no-fail", target("open")}, want: 0},
+		{name: "found below min-severity", args: []string{"-min-severity", "high", target("empty")}, want: 0},
+		{name: "found at min-severity", args: []string{"-min-severity", "medium", target("empty")}, want: exitFound},
+		{name: "error", args: []string{target("broken")}, want: exitError},
+		{name: "found and error", args: []string{target("open"), target("broken")}, want: exitFound},
+		{name: "timeout", args: []string{"-timeout", "200ms", target("slow")}, want: exitError},
+		{name: "timeout with findings", args: []string{"-timeout", "500ms", target("open"), target("slow")}, want: exitFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runGitwiki(t, append(base, tt.args...)...); got != tt.want {
+				t.Fatalf("exit status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStdinSkipsBlankLines(t *testing.T) {
+	mux := http.NewServeMux()
+	open := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(populatedWikiBody))
+	}
+	mux.HandleFunc("/open/r/wiki", open)
+	mux.HandleFunc("/open/r/wiki/", open)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	stdin := "\n   \nurl:" + srv.URL + "/open/r\n\n"
+	status, stdout, stderr := runGitwikiWithInput(t, stdin, "-skip-token-check", "-summary=false", "-probe-retries", "0", "-format", "json")
+	if status != exitFound {
+		t.Fatalf("exit status = %d, want %d\nstderr:\n%s", status, exitFound, stderr)
+	}
+	if strings.Contains(stdout+stderr, "cannot be empty") {
+		t.Errorf("blank stdin lines were scanned as targets\nstdout:\n%s\nstderr:\n%s", stdout, stderr)
+	}
+
+	var findings []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		var f map[string]any
+		if err := json.Unmarshal([]byte(line), &f); err != nil {
+			t.Fatalf("stdout isn't a JSON object per finding: %v\n%s", err, stdout)
+		}
+		findings = append(findings, f)
+	}
+	if len(findings) != 1 || findings[0]["url"] != srv.URL+"/open/r/wiki" {
+		t.Errorf("got findings %v, want the open wiki's", findings)
+	}
+}
+
+func TestOutputDirFailureFallsBackToSharedOutput(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/open/r/wiki", respond(http.StatusOK, populatedWikiBody))
+	mux.HandleFunc("/open/r/wiki/", respond(http.StatusOK, populatedWikiBody))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// A file where the directory should be means no account file can be created
+	notDir := filepath.Join(t.TempDir(), "findings")
+	if err := os.WriteFile(notDir, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	status, stdout, stderr := runGitwikiWithInput(t, "", "-skip-token-check", "-summary=false", "-probe-retries", "0",
+		"-format", "json", "-output-dir", notDir, "url:"+srv.URL+"/open/r")
+	if status != exitFound {
+		t.Fatalf("exit status = %d, want %d\nstderr:\n%s", status, exitFound, stderr)
+	}
+	if !strings.Contains(stderr, "create findings file") {
+		t.Errorf("the failed account file wasn't logged\nstderr:\n%s", stderr)
+	}
+	if !strings.Contains(stdout, srv.URL+"/open/r/wiki") {
+		t.Errorf("the account's finding didn't reach the shared output\nstdout:\n%s", stdout)
+	}
+}
+
+func TestInputFileComesBeforeArguments(t *testing.T) {
+	mux := http.NewServeMux()
+	for _, owner := range []string{"file", "arg", "stdin"} {
+		mux.HandleFunc("/"+owner+"/r/wiki", respond(http.StatusOK, populatedWikiBody))
+		mux.HandleFunc("/"+owner+"/r/wiki/", respond(http.StatusOK, populatedWikiBody))
+	}
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "targets.txt")
+	if err := os.WriteFile(path, []byte("# from a file\n\nurl:"+srv.URL+"/file/r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	status, stdout, stderr := runGitwikiWithInput(t, "url:"+srv.URL+"/stdin/r\n", "-skip-token-check", "-summary=false", "-probe-retries", "0",
+		"-format", "json", "-input-file", path, "url:"+srv.URL+"/arg/r")
+	if status != exitFound {
+		t.Fatalf("exit status = %d, want %d\nstderr:\n%s", status, exitFound, stderr)
+	}
+
+	var repos []string
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		var f struct {
+			Repo string `json:"repo"`
+		}
+		if err := json.Unmarshal([]byte(line), &f); err != nil {
+			t.Fatalf("%v\n%s", err, stdout)
+		}
+		repos = append(repos, f.Repo)
+	}
+	// Targets from flags replace stdin, and the file's come before the arguments
+	if strings.Join(repos, ",") != "file/r,arg/r" {
+		t.Errorf("scanned %v, want file/r then arg/r", repos)
+	}
+}
+
+func TestHeaderFlag(t *testing.T) {
+	headers := http.Header{}
+	for _, value := range []string{"X-Gateway-Route: github", "X-Team:  security ", "X-Team: audit"} {
+		if err := headerFlag(headers).Set(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, value := range []string{"no colon", ": no key"} {
+		if err := headerFlag(http.Header{}).Set(value); err == nil {
+			t.Errorf("-api-header %q was accepted", value)
+		}
+	}
+
+	if got := headers.Values("X-Gateway-Route"); len(got) != 1 || got[0] != "github" {
+		t.Errorf("X-Gateway-Route = %q, want just github", got)
+	}
+	if got := headers.Values("X-Team"); strings.Join(got, ",") != "security,audit" {
+		t.Errorf("X-Team = %q, want security and audit", got)
+	}
+}
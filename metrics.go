@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// Starts serving the scan's metrics on addr at /metrics
+func serveMetrics(addr string, metrics *scan.Metrics) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		metrics.Write(w)
+	})
+	go http.Serve(ln, mux)
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// How many findings a paced reporter holds before it starts dropping them
+const paceQueueSize = 1000
+
+var errPaceQueueFull = errors.New("output queue is full, dropped finding")
+
+// pacedReporter emits findings no faster than a fixed rate
+//
+// Findings are queued so a slow consumer never holds up the scan. When the queue fills up, findings are dropped
+// with an error rather than blocking.
+type pacedReporter struct {
+	next     scan.Reporter
+	interval time.Duration
+	queue    chan scan.Finding
+	done     chan struct{}
+}
+
+func newPacedReporter(next scan.Reporter, rps float64) *pacedReporter {
+	r := &pacedReporter{
+		next:     next,
+		interval: time.Duration(float64(time.Second) / rps),
+		queue:    make(chan scan.Finding, paceQueueSize),
+		done:     make(chan struct{}),
+	}
+	go r.run()
+
+	return r
+}
+
+// Works through the queue as a token bucket holding a single token
+func (r *pacedReporter) run() {
+	defer close(r.done)
+
+	var next time.Time
+	for f := range r.queue {
+		if wait := time.Until(next); wait > 0 {
+			time.Sleep(wait)
+		}
+		next = time.Now().Add(r.interval)
+
+		if err := r.next.Report(f); err != nil {
+			logError("error", "report", f.Account, f.Repo, err)
+		}
+	}
+}
+
+func (r *pacedReporter) Report(f scan.Finding) error {
+	select {
+	case r.queue <- f:
+		return nil
+	default:
+		return errPaceQueueFull
+	}
+}
+
+// Flushes the next reporter, which may still be writing findings from the queue
+func (r *pacedReporter) Flush() error {
+	return r.next.Flush()
+}
+
+// Emits everything still queued, at the same pace, then closes the next reporter
+func (r *pacedReporter) Close() error {
+	close(r.queue)
+	<-r.done
+
+	return r.next.Close()
+}
+
+// Wraps a reporter factory so its reporters emit at most rps findings a second, or leaves it alone for 0
+func pacing(rps float64, factory reporterFactory) reporterFactory {
+	if rps <= 0 {
+		return factory
+	}
+
+	return func(w io.Writer) scan.Reporter {
+		return newPacedReporter(factory(w), rps)
+	}
+}
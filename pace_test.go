@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// timingReporter records when each finding reached it, waiting for release first when it's set
+type timingReporter struct {
+	findingCollector
+	mu      sync.Mutex
+	times   []time.Time
+	release chan struct{}
+}
+
+func (r *timingReporter) Report(f scan.Finding) error {
+	if r.release != nil {
+		<-r.release
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.times = append(r.times, time.Now())
+
+	return r.findingCollector.Report(f)
+}
+
+func TestPacedReporterPacesFindings(t *testing.T) {
+	next := &timingReporter{}
+	r := newPacedReporter(next, 50)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := r.Report(scan.Finding{Repo: "repo"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+		t.Errorf("queueing findings took %s, the scan shouldn't wait on the pace", elapsed)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(next.findings) != 5 {
+		t.Fatalf("emitted %d findings, want 5", len(next.findings))
+	}
+	// At 50 a second the findings go out 20ms apart, give or take the scheduler
+	for i := 1; i < len(next.times); i++ {
+		if gap := next.times[i].Sub(next.times[i-1]); gap < 15*time.Millisecond {
+			t.Errorf("finding %d was emitted %s after the one before it, want about 20ms", i, gap)
+		}
+	}
+}
+
+func TestPacedReporterDropsWhenFull(t *testing.T) {
+	next := &timingReporter{release: make(chan struct{})}
+	r := newPacedReporter(next, 1000)
+
+	// One finding is held by the blocked reporter and the rest fill the queue
+	var err error
+	for i := 0; i <= paceQueueSize+1 && err == nil; i++ {
+		err = r.Report(scan.Finding{Repo: "repo"})
+	}
+	if !errors.Is(err, errPaceQueueFull) {
+		t.Errorf("reporting past a full queue = %v, want %v", err, errPaceQueueFull)
+	}
+
+	close(next.release)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
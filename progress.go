@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// Longest progress line, kept within a standard terminal so it can be erased without knowing the width
+const maxProgressWidth = 79
+
+// progressLine is a status line on stderr that's redrawn as a scan moves along, and erased whenever anything
+// else is written to the terminal so it never ends up mixed into logs or findings
+type progressLine struct {
+	mu      sync.Mutex
+	out     io.Writer
+	enabled bool
+	line    string
+	// Whether the line is on the terminal right now, rather than erased for something else being written
+	shown bool
+}
+
+// The scan's progress, only shown when stderr is a terminal and -quiet isn't set
+var progress = &progressLine{out: os.Stderr}
+
+// Whether f is a terminal rather than a file or a pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Shows the number of the repository being checked, out of an account's total
+func (p *progressLine) checking(n, total int, repo scan.Repository) {
+	name := repo.Name
+	if u, err := url.Parse(repo.URL); err == nil && u.Path != "" {
+		name = strings.Trim(u.Path, "/")
+	}
+
+	p.set(fmt.Sprintf("[%d/%d] checking %s", n, total, name))
+}
+
+func (p *progressLine) set(line string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.enabled {
+		return
+	}
+	if len(line) > maxProgressWidth {
+		line = line[:maxProgressWidth]
+	}
+	p.erase()
+	p.line = line
+	p.draw()
+}
+
+// Erases the line for good, before the summary and anything else written once the scan is done
+func (p *progressLine) clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.erase()
+	p.line = ""
+}
+
+func (p *progressLine) erase() {
+	if p.shown {
+		io.WriteString(p.out, "\r\033[K")
+		p.shown = false
+	}
+}
+
+func (p *progressLine) draw() {
+	if p.line != "" {
+		io.WriteString(p.out, p.line)
+		p.shown = true
+	}
+}
+
+// Gets a writer that erases the progress line around every write to w, for output going to the same terminal
+func (p *progressLine) around(w io.Writer) io.Writer {
+	return progressWriter{p: p, w: w}
+}
+
+type progressWriter struct {
+	p *progressLine
+	w io.Writer
+}
+
+func (w progressWriter) Write(b []byte) (int, error) {
+	w.p.mu.Lock()
+	defer w.p.mu.Unlock()
+
+	// Part of a line stays as it is until the rest of it comes, with the progress line drawn after it
+	w.p.erase()
+	n, err := w.w.Write(b)
+	if len(b) > 0 && b[len(b)-1] == '\n' {
+		w.p.draw()
+	}
+
+	return n, err
+}
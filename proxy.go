@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// proxyRule sends requests for hosts matching pattern through proxy, or directly when proxy is nil
+type proxyRule struct {
+	pattern string
+	proxy   *url.URL
+}
+
+// Per-host proxies, set with -proxy-for and -proxy-map, checked in order before the environment's proxy
+var proxyRules []proxyRule
+
+// Parses a "pattern=proxy" rule, where proxy can be "direct" to skip proxying
+func parseProxyRule(value string) (proxyRule, error) {
+	pattern, proxy, ok := strings.Cut(value, "=")
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	proxy = strings.TrimSpace(proxy)
+	if !ok || pattern == "" || proxy == "" {
+		return proxyRule{}, fmt.Errorf("proxy rule must be in the form host=proxy, got %q", value)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return proxyRule{}, fmt.Errorf("invalid host pattern %q: %w", pattern, err)
+	}
+
+	if proxy == "direct" {
+		return proxyRule{pattern: pattern}, nil
+	}
+
+	u, err := url.Parse(proxy)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return proxyRule{}, fmt.Errorf("invalid proxy URL %q", proxy)
+	}
+
+	return proxyRule{pattern: pattern, proxy: u}, nil
+}
+
+// proxyRuleFlag adds a rule to proxyRules each time it's set
+type proxyRuleFlag struct{}
+
+func (proxyRuleFlag) String() string {
+	return ""
+}
+
+func (proxyRuleFlag) Set(value string) error {
+	rule, err := parseProxyRule(value)
+	if err != nil {
+		return err
+	}
+	proxyRules = append(proxyRules, rule)
+
+	return nil
+}
+
+// Proxy for every host without a rule, in place of the environment's, set with -proxy
+var defaultProxy *url.URL
+
+// Proxy schemes the transport can use
+var proxySchemes = map[string]bool{"http": true, "https": true, "socks5": true, "socks5h": true}
+
+// Sets the proxy from -proxy, an http, https or socks5 URL
+func setDefaultProxy(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" || !proxySchemes[u.Scheme] {
+		return fmt.Errorf("invalid proxy URL %q, must be an http, https or socks5 URL", value)
+	}
+	defaultProxy = u
+
+	return nil
+}
+
+// Loads proxy rules from a file with one host=proxy rule per line, skipping blank lines and # comments
+func loadProxyMap(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		rule, err := parseProxyRule(text)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", filename, line, err)
+		}
+		proxyRules = append(proxyRules, rule)
+	}
+
+	return scanner.Err()
+}
+
+// Picks the proxy for a request from the first matching rule, falling back to -proxy and then the environment
+func proxyForRequest(req *http.Request) (*url.URL, error) {
+	host := strings.ToLower(req.URL.Hostname())
+	for _, rule := range proxyRules {
+		if matched, _ := path.Match(rule.pattern, host); matched {
+			return rule.proxy, nil
+		}
+	}
+	if defaultProxy != nil {
+		return defaultProxy, nil
+	}
+
+	return http.ProxyFromEnvironment(req)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+func TestProxyRules(t *testing.T) {
+	oldRules, oldDefault := proxyRules, defaultProxy
+	defer func() { proxyRules, defaultProxy = oldRules, oldDefault }()
+
+	// Each proxy answers with its name, so the response says which one the request went through
+	proxy := func(name string) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Proxy", name)
+			fmt.Fprint(w, r.URL.String())
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	egress, mirror, fallback := proxy("egress"), proxy("mirror"), proxy("fallback")
+
+	mapFile := filepath.Join(t.TempDir(), "proxies")
+	content := "# internal mirrors\n\n*.mirror.test=" + mirror.URL + "\n"
+	if err := os.WriteFile(mapFile, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	proxyRules = nil
+	if err := (proxyRuleFlag{}).Set("github.test=" + egress.URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadProxyMap(mapFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := setDefaultProxy(fallback.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := scan.NewScanner()
+	scanner.Proxy = proxyForRequest
+	client := scanner.NewClient()
+	tests := map[string]string{
+		"http://github.test/acme/docs/wiki":     "egress",
+		"http://GitHub.test/acme/docs/wiki":     "egress",
+		"http://git.mirror.test/acme/docs/wiki": "mirror",
+		"http://elsewhere.test/acme/docs/wiki":  "fallback",
+	}
+	for target, want := range tests {
+		resp, err := client.Get(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := resp.Header.Get("X-Proxy"); got != want {
+			t.Errorf("%s went through the %s proxy, want %s", target, got, want)
+		}
+	}
+}
+
+func TestProxyRuleDirect(t *testing.T) {
+	oldRules, oldDefault := proxyRules, defaultProxy
+	defer func() { proxyRules, defaultProxy = oldRules, oldDefault }()
+	proxyRules = nil
+	if err := setDefaultProxy("http://egress.test:3128"); err != nil {
+		t.Fatal(err)
+	}
+	if err := (proxyRuleFlag{}).Set("*.internal.test=direct"); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://wiki.internal.test/acme/docs/wiki", nil)
+	if proxy, err := proxyForRequest(req); err != nil || proxy != nil {
+		t.Errorf("direct host went through %v (%v), want no proxy", proxy, err)
+	}
+
+	for _, rule := range []string{"github.com", "=http://proxy.test", "github.com=", "[=http://proxy.test", "github.com=proxy.test"} {
+		if _, err := parseProxyRule(rule); err == nil {
+			t.Errorf("proxy rule %q was accepted", rule)
+		}
+	}
+}
@@ -0,0 +1,569 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/offftherecord/gitwiki/internal/atomicfile"
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// Labels for probe results in text output
+var textLabels = map[scan.ProbeResult]string{
+	scan.ProbeNoWiki:        "No-Wiki",
+	scan.ProbeRequiresAuth:  "Requires-Auth",
+	scan.ProbeEmpty:         "Writable-Firstpage",
+	scan.ProbeWriteable:     "Writable",
+	scan.ProbeDisabled:      "Disabled",
+	scan.ProbeError:         "Error",
+	scan.ProbeSoftNotFound:  "Soft-Not-Found",
+	scan.ProbeReadOnly:      "Read-Only",
+	scan.ProbeUnexpected:    "Unexpected",
+	scan.ProbeOrgDisabled:   "Org-Disabled",
+	scan.ProbeThrottled:     "Throttled",
+	scan.ProbeInvalidURL:    "Invalid-URL",
+	scan.ProbeReadable:      "Readable",
+	scan.ProbeAdminDisabled: "Admin-Disabled",
+	scan.ProbeRuleMatch:     "Rule-Match",
+}
+
+// How severities rank against each other, for -min-severity
+var severityRanks = map[string]int{
+	"medium": 1,
+	"high":   2,
+}
+
+// Lowest severity reported, set with -min-severity ("" reports findings without one too)
+var minSeverity string
+
+// Sets the lowest severity reported from a flag value
+func setMinSeverity(value string) error {
+	if _, ok := severityRanks[value]; !ok {
+		return fmt.Errorf("severity must be medium or high, got %q", value)
+	}
+	minSeverity = value
+
+	return nil
+}
+
+// Whether a finding is at least as severe as -min-severity asks for
+func severeEnough(f scan.Finding) bool {
+	return minSeverity == "" || severityRanks[f.Severity()] >= severityRanks[minSeverity]
+}
+
+// findingRecord is a finding as JSON
+type findingRecord struct {
+	Account string `json:"account"`
+	Repo    string `json:"repo"`
+	URL     string `json:"url"`
+	Result  string `json:"result"`
+	Private bool   `json:"private,omitempty"`
+	// firstpage, writeable, writeable-authenticated or rule-match for vulnerable wikis, and how severe that is
+	Vulnerability string   `json:"vulnerability,omitempty"`
+	Severity      string   `json:"severity,omitempty"`
+	Rule          string   `json:"rule,omitempty"`
+	Redirects     []string `json:"redirects,omitempty"`
+	LastAuthor    string   `json:"last_author,omitempty"`
+	LastEdited    string   `json:"last_edited,omitempty"`
+	CheckedAt     string   `json:"checked_at"`
+}
+
+func newFindingRecord(f scan.Finding) findingRecord {
+	record := findingRecord{
+		Account:    f.Account,
+		Repo:       f.Repo,
+		URL:        f.URL,
+		Result:     f.Result.String(),
+		Private:    f.Private,
+		Rule:       f.Rule,
+		Redirects:  f.Redirects,
+		LastAuthor: f.LastAuthor,
+		CheckedAt:  f.CheckedAt.UTC().Format(time.RFC3339),
+	}
+	record.Vulnerability = f.Vulnerability()
+	record.Severity = f.Severity()
+	if !f.LastEdited.IsZero() {
+		record.LastEdited = f.LastEdited.UTC().Format(time.RFC3339)
+	}
+
+	return record
+}
+
+// reporterFactory creates a reporter writing to w, so every output gets the same format
+type reporterFactory func(w io.Writer) scan.Reporter
+
+// textReporter writes one human readable line per finding
+type textReporter struct {
+	w *bufio.Writer
+}
+
+func newTextReporter(w io.Writer) scan.Reporter {
+	return &textReporter{w: bufio.NewWriter(w)}
+}
+
+func (r *textReporter) Report(f scan.Finding) error {
+	line := fmt.Sprintf("%s: %s, URL: %s", textLabels[f.Result], f.Repo, f.URL)
+	if f.Private {
+		line += ", Private"
+	}
+	if f.SignedInOnly {
+		line += ", Signed in only"
+	}
+	if f.Rule != "" {
+		line += ", Rule: " + f.Rule
+	}
+	if len(f.Redirects) > 0 {
+		line += ", Redirects: " + strings.Join(f.Redirects, " -> ")
+	}
+	if f.LastAuthor != "" {
+		line += fmt.Sprintf(", Last edited: %s by %s", f.LastEdited.Format(time.RFC3339), f.LastAuthor)
+	}
+
+	_, err := fmt.Fprintln(r.w, line)
+	return err
+}
+
+func (r *textReporter) Flush() error {
+	return r.w.Flush()
+}
+
+func (r *textReporter) Close() error {
+	return r.w.Flush()
+}
+
+// outputFormat is a -format choice
+type outputFormat struct {
+	factory reporterFactory
+	// Extension for files written in this format
+	ext string
+}
+
+// Output formats for -format
+var outputFormats = map[string]outputFormat{
+	"text":        {factory: newTextReporter, ext: ".txt"},
+	"stable-text": {factory: newStableTextReporter, ext: ".txt"},
+	"null":        {factory: newNullReporter, ext: ".nul"},
+	"json":        {factory: newJSONReporter, ext: ".jsonl"},
+	"ndjson":      {factory: newNDJSONReporter, ext: ".ndjson"},
+	"csv":         {factory: newCSVReporter, ext: ".csv"},
+	"sarif":       {factory: newSARIFReporter, ext: ".sarif"},
+	"table":       {factory: newTableReporter, ext: ".txt"},
+}
+
+// Gets the names of all output formats, sorted
+func outputFormatList() []string {
+	var names []string
+	for name := range outputFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// stableTextReporter writes findings sorted by account, repository, result and URL, with nothing that changes
+// between runs, so two runs over the same wikis produce identical output
+//
+// Findings are buffered until Close.
+type stableTextReporter struct {
+	w        io.Writer
+	findings []scan.Finding
+}
+
+func newStableTextReporter(w io.Writer) scan.Reporter {
+	return &stableTextReporter{w: w}
+}
+
+func (r *stableTextReporter) Report(f scan.Finding) error {
+	r.findings = append(r.findings, f)
+	return nil
+}
+
+// Sorted output can't be written until every finding is in, so there's nothing to flush before Close
+func (r *stableTextReporter) Flush() error {
+	return nil
+}
+
+func (r *stableTextReporter) Close() error {
+	sort.Slice(r.findings, func(i, j int) bool {
+		a, b := r.findings[i], r.findings[j]
+		if a.Account != b.Account {
+			return a.Account < b.Account
+		}
+		if a.Repo != b.Repo {
+			return a.Repo < b.Repo
+		}
+		if a.Result != b.Result {
+			return a.Result < b.Result
+		}
+		return a.URL < b.URL
+	})
+
+	for _, f := range r.findings {
+		if _, err := fmt.Fprintf(r.w, "%s\t%s\t%s\t%s\n", f.Account, f.Repo, f.Result, f.URL); err != nil {
+			return err
+		}
+	}
+	r.findings = nil
+
+	return nil
+}
+
+// tableReporter writes findings as a table with aligned columns, in the order they were found
+//
+// Columns can't be aligned until every finding is in, so they're buffered until Close.
+type tableReporter struct {
+	w        io.Writer
+	findings []scan.Finding
+}
+
+func newTableReporter(w io.Writer) scan.Reporter {
+	return &tableReporter{w: w}
+}
+
+func (r *tableReporter) Report(f scan.Finding) error {
+	r.findings = append(r.findings, f)
+	return nil
+}
+
+func (r *tableReporter) Flush() error {
+	return nil
+}
+
+// Writes the header even when there were no findings
+func (r *tableReporter) Close() error {
+	tw := tabwriter.NewWriter(r.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACCOUNT\tREPO\tTYPE\tURL")
+	for _, f := range r.findings {
+		// Vulnerable wikis go by how they're vulnerable, the rest that -show lets through by their result
+		kind := f.Vulnerability()
+		if kind == "" {
+			kind = f.Result.String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Account, f.Repo, kind, f.URL)
+	}
+	r.findings = nil
+
+	return tw.Flush()
+}
+
+// jsonReporter writes one JSON object per line for each finding
+type jsonReporter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func newJSONReporter(w io.Writer) scan.Reporter {
+	bw := bufio.NewWriter(w)
+	return &jsonReporter{w: bw, enc: json.NewEncoder(bw)}
+}
+
+func (r *jsonReporter) Report(f scan.Finding) error {
+	return r.enc.Encode(newFindingRecord(f))
+}
+
+func (r *jsonReporter) Flush() error {
+	return r.w.Flush()
+}
+
+func (r *jsonReporter) Close() error {
+	return r.w.Flush()
+}
+
+// ndjsonReporter writes the same objects as jsonReporter, each flushed as soon as it's written, so a pipeline
+// reading from a file or pipe gets every finding as it's found
+type ndjsonReporter struct {
+	*jsonReporter
+}
+
+func newNDJSONReporter(w io.Writer) scan.Reporter {
+	return ndjsonReporter{newJSONReporter(w).(*jsonReporter)}
+}
+
+func (r ndjsonReporter) Report(f scan.Finding) error {
+	if err := r.jsonReporter.Report(f); err != nil {
+		return err
+	}
+
+	return r.w.Flush()
+}
+
+// Columns written by the csv format
+var csvHeader = []string{"account", "repo", "repo_url", "wiki_url", "vulnerability", "checked_at", "result"}
+
+// csvReporter writes a header row and then a row per finding
+//
+// The header is written once, with the first finding, so findings from every account end up in one CSV.
+type csvReporter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func newCSVReporter(w io.Writer) scan.Reporter {
+	return &csvReporter{w: csv.NewWriter(w)}
+}
+
+func (r *csvReporter) header() error {
+	if r.wroteHeader {
+		return nil
+	}
+	r.wroteHeader = true
+
+	return r.w.Write(csvHeader)
+}
+
+func (r *csvReporter) Report(f scan.Finding) error {
+	if err := r.header(); err != nil {
+		return err
+	}
+
+	wikiURL := ""
+	if f.Result != scan.ProbeNoWiki && f.RepoURL != "" {
+		wikiURL = f.RepoURL + "/wiki"
+	}
+
+	return r.w.Write([]string{
+		f.Account,
+		f.Repo,
+		f.RepoURL,
+		wikiURL,
+		f.Vulnerability(),
+		f.CheckedAt.UTC().Format(time.RFC3339),
+		f.Result.String(),
+	})
+}
+
+func (r *csvReporter) Flush() error {
+	r.w.Flush()
+	return r.w.Error()
+}
+
+// Writes the header even when there were no findings, so the output is always a valid CSV
+func (r *csvReporter) Close() error {
+	if err := r.header(); err != nil {
+		return err
+	}
+
+	return r.Flush()
+}
+
+// Fields of a finding the null format can write
+var nullFields = map[string]func(f scan.Finding) string{
+	"url":     func(f scan.Finding) string { return f.URL },
+	"repo":    func(f scan.Finding) string { return f.Repo },
+	"account": func(f scan.Finding) string { return f.Account },
+	"result":  func(f scan.Finding) string { return f.Result.String() },
+}
+
+// Field written by the null format, set with -null-field
+var nullField = "url"
+
+// nullReporter writes one field of each finding followed by a NUL, for xargs -0
+type nullReporter struct {
+	w     *bufio.Writer
+	field func(f scan.Finding) string
+}
+
+func newNullReporter(w io.Writer) scan.Reporter {
+	return &nullReporter{w: bufio.NewWriter(w), field: nullFields[nullField]}
+}
+
+func (r *nullReporter) Report(f scan.Finding) error {
+	_, err := r.w.WriteString(r.field(f) + "\x00")
+	return err
+}
+
+func (r *nullReporter) Flush() error {
+	return r.w.Flush()
+}
+
+func (r *nullReporter) Close() error {
+	return r.w.Flush()
+}
+
+// Gets the names of all probe results, in order
+func probeResultList() []string {
+	var names []string
+	for _, r := range scan.ProbeResults() {
+		names = append(names, r.String())
+	}
+
+	return names
+}
+
+// Parses the -show flag into the set of results to report
+func parseShow(value string) (map[scan.ProbeResult]bool, error) {
+	shown := make(map[scan.ProbeResult]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+			continue
+		case "all":
+			for _, r := range scan.ProbeResults() {
+				shown[r] = true
+			}
+		case "vulnerable":
+			shown[scan.ProbeEmpty] = true
+			shown[scan.ProbeWriteable] = true
+			shown[scan.ProbeRuleMatch] = true
+		case "safe":
+			for _, r := range scan.ProbeResults() {
+				if r.Safe() {
+					shown[r] = true
+				}
+			}
+		default:
+			result, ok := scan.ParseProbeResult(name)
+			if !ok {
+				return nil, fmt.Errorf("unknown result %q in -show", name)
+			}
+			shown[result] = true
+		}
+	}
+
+	return shown, nil
+}
+
+// showReporter only passes on findings with a result that's been asked for, as severe as -min-severity asks
+type showReporter struct {
+	scan.Reporter
+	shown map[scan.ProbeResult]bool
+}
+
+func (r *showReporter) Report(f scan.Finding) error {
+	if !r.shown[f.Result] || !severeEnough(f) {
+		return nil
+	}
+
+	return r.Reporter.Report(f)
+}
+
+// Wraps a reporter factory so its reporters only report the shown results
+func showing(shown map[scan.ProbeResult]bool, factory reporterFactory) reporterFactory {
+	return func(w io.Writer) scan.Reporter {
+		return &showReporter{Reporter: factory(w), shown: shown}
+	}
+}
+
+// foundCounter counts the vulnerable wikis as severe as -min-severity asks for, which make the run exit with
+// exitFound
+type foundCounter struct {
+	n atomic.Int64
+}
+
+func (c *foundCounter) Report(f scan.Finding) error {
+	if f.Result.Vulnerable() && severeEnough(f) {
+		c.n.Add(1)
+	}
+
+	return nil
+}
+
+func (c *foundCounter) Flush() error {
+	return nil
+}
+
+func (c *foundCounter) Close() error {
+	return nil
+}
+
+// multiReporter sends every finding to several reporters
+type multiReporter []scan.Reporter
+
+func (m multiReporter) Report(f scan.Finding) error {
+	var firstErr error
+	for _, r := range m {
+		if err := r.Report(f); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+func (m multiReporter) Flush() error {
+	var firstErr error
+	for _, r := range m {
+		if err := r.Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+func (m multiReporter) Close() error {
+	var firstErr error
+	for _, r := range m {
+		if err := r.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// Turns an account into a file name that can't escape the output directory
+func accountFileName(account, ext string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, account)
+
+	if strings.Trim(name, ".") == "" {
+		name = strings.Repeat("_", max(len(name), 1))
+	}
+
+	return name + ext
+}
+
+// fileReporter closes its file after the reporter writing to it
+type fileReporter struct {
+	scan.Reporter
+	file io.Closer
+}
+
+func (r *fileReporter) Close() error {
+	err := r.Reporter.Close()
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// Creates a reporter for an account's findings file in dir, which is only put in place once it's closed
+func newAccountReporter(dir, account, ext string, factory reporterFactory) (scan.Reporter, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	if outputCompression == "gzip" {
+		ext += ".gz"
+	}
+	f, err := atomicfile.Create(filepath.Join(dir, accountFileName(account, ext)))
+	if err != nil {
+		return nil, err
+	}
+	if outputCompression == "gzip" {
+		gz := newGzipFile(f)
+		return &fileReporter{Reporter: factory(gz), file: gz}, nil
+	}
+
+	return &fileReporter{Reporter: factory(f), file: f}, nil
+}
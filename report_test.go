@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+func TestAccountReportersWriteAFilePerAccount(t *testing.T) {
+	oldCompression := outputCompression
+	defer func() { outputCompression = oldCompression }()
+	outputCompression = "none"
+
+	dir := filepath.Join(t.TempDir(), "findings")
+	findings := map[string][]scan.Finding{
+		"acme":   {{Account: "acme", Repo: "docs", URL: "https://github.com/acme/docs/wiki", Result: scan.ProbeWriteable}},
+		"globex": {{Account: "globex", Repo: "handbook", URL: "https://github.com/globex/handbook/wiki", Result: scan.ProbeEmpty}},
+	}
+
+	for account, fs := range findings {
+		r, err := newAccountReporter(dir, account, ".ndjson", newNDJSONReporter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range fs {
+			if err := r.Report(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, account+".ndjson")); !os.IsNotExist(err) {
+			t.Errorf("%s's findings file is in place before it's closed", account)
+		}
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(findings) {
+		t.Errorf("got %d files, want one per account", len(entries))
+	}
+	for account, fs := range findings {
+		data, err := os.ReadFile(filepath.Join(dir, account+".ndjson"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(lines) != len(fs) || !strings.Contains(lines[0], `"url":"`+fs[0].URL+`"`) {
+			t.Errorf("%s's findings file is:\n%s", account, data)
+		}
+		for other := range findings {
+			if other != account && strings.Contains(string(data), `"account":"`+other+`"`) {
+				t.Errorf("%s's findings file has %s's findings:\n%s", account, other, data)
+			}
+		}
+	}
+}
+
+func TestAccountFileName(t *testing.T) {
+	tests := map[string]string{
+		"acme":                     "acme.json",
+		"org:acme":                 "org_acme.json",
+		"../../etc/passwd":         ".._.._etc_passwd.json",
+		"..":                       "__.json",
+		"":                         "_.json",
+		"search:topic:docs lang:x": "search_topic_docs_lang_x.json",
+	}
+	for account, want := range tests {
+		if got := accountFileName(account, ".json"); got != want {
+			t.Errorf("accountFileName(%q) = %q, want %q", account, got, want)
+		}
+	}
+}
+
+func TestShowReporter(t *testing.T) {
+	results := scan.ProbeResults()
+
+	tests := []struct {
+		show string
+		want []scan.ProbeResult
+	}{
+		{show: "vulnerable", want: []scan.ProbeResult{scan.ProbeEmpty, scan.ProbeWriteable, scan.ProbeRuleMatch}},
+		{show: "all", want: results},
+		{show: "safe", want: []scan.ProbeResult{scan.ProbeRequiresAuth, scan.ProbeSoftNotFound, scan.ProbeReadOnly, scan.ProbeReadable}},
+		{show: "error, no-wiki", want: []scan.ProbeResult{scan.ProbeError, scan.ProbeNoWiki}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.show, func(t *testing.T) {
+			shown, err := parseShow(tt.show)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var c findingCollector
+			r := &showReporter{Reporter: &c, shown: shown}
+			for _, result := range results {
+				if err := r.Report(scan.Finding{Repo: result.String(), Result: result}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want := map[string]bool{}
+			for _, result := range tt.want {
+				want[result.String()] = true
+			}
+			if len(c.findings) != len(want) {
+				t.Errorf("reported %d findings, want %d", len(c.findings), len(want))
+			}
+			for _, f := range c.findings {
+				if !want[f.Repo] {
+					t.Errorf("reported a %s finding", f.Result)
+				}
+			}
+		})
+	}
+
+	if _, err := parseShow("vulnerable,bogus"); err == nil {
+		t.Error("-show with an unknown result was accepted")
+	}
+}
+
+func TestStableTextIsIdenticalAcrossRuns(t *testing.T) {
+	repos := []scan.Repository{
+		{Name: "docs", URL: "/acme/docs", HasWiki: true},
+		{Name: "blank", URL: "/acme/blank", HasWiki: true},
+		{Name: "locked", URL: "/acme/locked", HasWiki: true},
+		{Name: "code", URL: "/acme/code"},
+	}
+	reversed := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/acme/repos", func(w http.ResponseWriter, r *http.Request) {
+		listed := make([]scan.Repository, len(repos))
+		for i, repo := range repos {
+			repo.URL = "http://" + r.Host + repo.URL
+			repo.Visibility = "public"
+			listed[i] = repo
+		}
+		if reversed {
+			for i, j := 0, len(listed)-1; i < j; i, j = i+1, j-1 {
+				listed[i], listed[j] = listed[j], listed[i]
+			}
+		}
+		json.NewEncoder(w).Encode(listed)
+	})
+	mux.HandleFunc("/acme/docs/wiki", respond(http.StatusOK, populatedWikiBody))
+	mux.HandleFunc("/acme/docs/wiki/", respond(http.StatusOK, populatedWikiBody))
+	mux.HandleFunc("/acme/blank/wiki", respond(http.StatusOK, populatedWikiBody+scan.WikiFirstPageMarker))
+	mux.HandleFunc("/acme/locked/wiki", respond(http.StatusOK, populatedWikiBody))
+	mux.Handle("/acme/locked/wiki/", http.RedirectHandler("/login", http.StatusFound))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// Each run picks its own random test page, which mustn't show in the output
+	scanner := scan.NewScanner()
+	scanner.APIURL = srv.URL
+	scanner.ProbeRetries = 0
+
+	scanOnce := func() string {
+		t.Helper()
+		var buf bytes.Buffer
+		r := newStableTextReporter(&buf)
+		if err := scanner.NewRun().Scan(context.Background(), "acme", r); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	first := scanOnce()
+	reversed = true
+	second := scanOnce()
+
+	if first != second {
+		t.Errorf("runs over the same wikis differ:\n%s\nthen:\n%s", first, second)
+	}
+	if lines := strings.Count(first, "\n"); lines != len(repos) {
+		t.Errorf("got %d lines, want one per repository:\n%s", lines, first)
+	}
+}
+
+// findingCollector keeps the findings reported to it
+type findingCollector struct {
+	findings []scan.Finding
+}
+
+func (c *findingCollector) Report(f scan.Finding) error {
+	c.findings = append(c.findings, f)
+	return nil
+}
+
+func (c *findingCollector) Flush() error {
+	return nil
+}
+
+func (c *findingCollector) Close() error {
+	return nil
+}
+
+func TestNullReporter(t *testing.T) {
+	oldField := nullField
+	defer func() { nullField = oldField }()
+
+	// URLs and names can hold anything but a NUL, newlines and spaces included
+	findings := []scan.Finding{
+		{Account: "acme", Repo: "docs", URL: "https://github.com/acme/docs/wiki", Result: scan.ProbeWriteable},
+		{Account: "acme corp", Repo: "odd\nname", URL: "https://git.example.com/acme corp/odd%0Aname/wiki", Result: scan.ProbeEmpty},
+	}
+
+	tests := map[string][]string{
+		"url":     {findings[0].URL, findings[1].URL},
+		"repo":    {"docs", "odd\nname"},
+		"account": {"acme", "acme corp"},
+		"result":  {"writeable", "empty"},
+	}
+	for field, want := range tests {
+		nullField = field
+		var buf bytes.Buffer
+		r := newNullReporter(&buf)
+		for _, f := range findings {
+			if err := r.Report(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := buf.String(), strings.Join(want, "\x00")+"\x00"; got != want {
+			t.Errorf("-null-field %s wrote %q, want %q", field, got, want)
+		}
+	}
+}
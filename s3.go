@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// Uploads a file to S3, only set when built with the s3 tag
+var uploadToS3 func(ctx context.Context, bucket, key string, body io.Reader) error
+
+var errNoS3 = errors.New("this build doesn't support S3 uploads, rebuild with -tags s3")
+
+// Parses an s3://bucket/prefix destination
+func parseS3URL(rawURL string) (bucket, prefix string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme != "s3" || u.Host == "" {
+		return "", "", fmt.Errorf("S3 destination must be in the form s3://bucket/prefix, got %q", rawURL)
+	}
+
+	return u.Host, strings.Trim(u.Path, "/"), nil
+}
+
+// s3Upload collects the run's findings in a local file that's uploaded to S3 at the end
+type s3Upload struct {
+	bucket string
+	key    string
+	file   *os.File
+	scan.Reporter
+}
+
+// Starts collecting the findings of the scan with scanID for an upload to dest, in the format the factory writes
+func newS3Upload(dest, scanID, ext string, factory reporterFactory) (*s3Upload, error) {
+	if uploadToS3 == nil {
+		return nil, errNoS3
+	}
+
+	bucket, prefix, err := parseS3URL(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("gitwiki-%s-%s%s", scanID, time.Now().UTC().Format("20060102T150405Z"), ext)
+	f, err := os.CreateTemp("", "gitwiki-*"+ext)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Upload{bucket: bucket, key: path.Join(prefix, name), file: f, Reporter: factory(f)}, nil
+}
+
+// Finishes the findings file and uploads it, keeping the local copy if the upload fails
+func (u *s3Upload) Close() error {
+	if err := u.Reporter.Close(); err != nil {
+		u.file.Close()
+		return fmt.Errorf("writing findings for S3, partial file kept at %s: %w", u.file.Name(), err)
+	}
+
+	if _, err := u.file.Seek(0, io.SeekStart); err != nil {
+		u.file.Close()
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	err := uploadToS3(ctx, u.bucket, u.key, u.file)
+	u.file.Close()
+	if err != nil {
+		return fmt.Errorf("uploading to s3://%s/%s, findings kept at %s: %w", u.bucket, u.key, u.file.Name(), err)
+	}
+
+	return os.Remove(u.file.Name())
+}
@@ -0,0 +1,41 @@
+//go:build s3
+
+package main
+
+import (
+	"context"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func init() {
+	uploadToS3 = uploadWithSDK
+}
+
+// s3PutObjectAPI is the part of the S3 client an upload needs, so it can be swapped for a fake
+type s3PutObjectAPI interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// Uploads with the AWS SDK, resolving credentials and region the standard way
+func uploadWithSDK(ctx context.Context, bucket, key string, body io.Reader) error {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	return putObject(ctx, s3.NewFromConfig(cfg), bucket, key, body)
+}
+
+func putObject(ctx context.Context, client s3PutObjectAPI, bucket, key string, body io.Reader) error {
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   body,
+	})
+
+	return err
+}
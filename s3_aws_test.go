@@ -0,0 +1,104 @@
+//go:build s3
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// fakeS3 keeps the objects put to it, or fails every put with err
+type fakeS3 struct {
+	objects map[string]string
+	err     error
+}
+
+func (c *fakeS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)] = string(body)
+
+	return &s3.PutObjectOutput{}, nil
+}
+
+// Routes uploads to client for the rest of the test
+func useFakeS3(t *testing.T, client *fakeS3) {
+	saved := uploadToS3
+	uploadToS3 = func(ctx context.Context, bucket, key string, body io.Reader) error {
+		return putObject(ctx, client, bucket, key, body)
+	}
+	t.Cleanup(func() { uploadToS3 = saved })
+}
+
+func TestS3UploadPutsFindings(t *testing.T) {
+	client := &fakeS3{objects: map[string]string{}}
+	useFakeS3(t, client)
+
+	scanID := "20261014T120000Z-test"
+	u, err := newS3Upload("s3://findings/scans/nightly/", scanID, ".ndjson", newNDJSONReporter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := u.file.Name()
+	if err := u.Report(scan.Finding{Account: "acme", Repo: "docs", URL: "https://github.com/acme/docs/wiki", Result: scan.ProbeWriteable}); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(client.objects) != 1 {
+		t.Fatalf("got %d objects, want 1: %v", len(client.objects), client.objects)
+	}
+	for key, body := range client.objects {
+		if dir, name := path.Split(key); dir != "findings/scans/nightly/" || !strings.HasPrefix(name, "gitwiki-"+scanID+"-") || !strings.HasSuffix(name, ".ndjson") {
+			t.Errorf("object was put at %q", key)
+		}
+		if !strings.Contains(body, `"https://github.com/acme/docs/wiki"`) {
+			t.Errorf("object is missing the finding:\n%s", body)
+		}
+	}
+	if _, err := os.Stat(local); !os.IsNotExist(err) {
+		t.Errorf("local findings file %s wasn't removed after the upload", local)
+	}
+}
+
+func TestS3UploadFailureKeepsFindings(t *testing.T) {
+	useFakeS3(t, &fakeS3{err: errors.New("access denied")})
+
+	u, err := newS3Upload("s3://findings", "20261014T120000Z-test", ".ndjson", newNDJSONReporter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := u.file.Name()
+	defer os.Remove(local)
+	if err := u.Report(scan.Finding{Account: "acme", Repo: "docs", URL: "https://github.com/acme/docs/wiki", Result: scan.ProbeWriteable}); err != nil {
+		t.Fatal(err)
+	}
+
+	err = u.Close()
+	if err == nil || !strings.Contains(err.Error(), local) || !strings.Contains(err.Error(), "access denied") {
+		t.Fatalf("Close() = %v, want the upload error naming %s", err, local)
+	}
+	kept, err := os.ReadFile(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(kept), "acme/docs") {
+		t.Errorf("kept findings file is missing the finding:\n%s", kept)
+	}
+}
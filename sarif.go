@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// Schema and version of the SARIF documents the sarif format writes
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+// sarifRule describes one way a wiki can be vulnerable, referred to by the results of that kind
+type sarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	FullDescription  sarifMessage `json:"fullDescription"`
+	Help             sarifMessage `json:"help"`
+	Properties       sarifProps   `json:"properties"`
+}
+
+type sarifProps struct {
+	Tags             []string `json:"tags,omitempty"`
+	SecuritySeverity string   `json:"security-severity,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+// Rules for the sarif format, by the vulnerability of the findings they cover
+var sarifRules = map[string]sarifRule{
+	"firstpage": {
+		ID:               "firstpage-wiki",
+		Name:             "FirstPageWiki",
+		ShortDescription: sarifMessage{"Wiki without pages can be created by anyone"},
+		FullDescription:  sarifMessage{"The repository's wiki is enabled but has no pages, and any signed in GitHub user can create its first page."},
+		Help:             sarifMessage{"Turn off the wiki in the repository's settings, or restrict editing to collaborators."},
+		Properties:       sarifProps{Tags: []string{"security", "wiki"}, SecuritySeverity: "6.5"},
+	},
+	"writeable": {
+		ID:               "writeable-wiki",
+		Name:             "WriteableWiki",
+		ShortDescription: sarifMessage{"Wiki can be edited by anyone"},
+		FullDescription:  sarifMessage{"Any signed in GitHub user can create and edit pages in the repository's wiki."},
+		Help:             sarifMessage{"Restrict wiki editing to collaborators in the repository's settings, or turn off the wiki."},
+		Properties:       sarifProps{Tags: []string{"security", "wiki"}, SecuritySeverity: "7.5"},
+	},
+	"rule-match": {
+		ID:               "rule-match-wiki",
+		Name:             "RuleMatchWiki",
+		ShortDescription: sarifMessage{"Wiki matches a custom rule"},
+		FullDescription:  sarifMessage{"The wiki's landing page matched one of the patterns in the rules file the scan was given."},
+		Help:             sarifMessage{"Check the wiki against the rule it matched, named in the result's properties."},
+		Properties:       sarifProps{Tags: []string{"security", "wiki"}, SecuritySeverity: "5.0"},
+	},
+	"writeable-authenticated": {
+		ID:               "writeable-authenticated-wiki",
+		Name:             "WriteableAuthenticatedWiki",
+		ShortDescription: sarifMessage{"Wiki can be edited by any signed in user"},
+		FullDescription:  sarifMessage{"Any signed in GitHub user can create and edit pages in the repository's wiki, though it turns away visitors who aren't signed in."},
+		Help:             sarifMessage{"Restrict wiki editing to collaborators in the repository's settings, or turn off the wiki."},
+		Properties:       sarifProps{Tags: []string{"security", "wiki"}, SecuritySeverity: "7.5"},
+	},
+}
+
+// SARIF levels for each severity
+var sarifLevels = map[string]string{
+	"high":   "error",
+	"medium": "warning",
+}
+
+// Rules in the order they're listed in the document
+var sarifRuleOrder = []string{"firstpage", "writeable", "writeable-authenticated", "rule-match"}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	Properties          sarifResultProps  `json:"properties"`
+}
+
+type sarifResultProps struct {
+	Account string `json:"account"`
+	Repo    string `json:"repo"`
+	RepoURL string `json:"repo_url,omitempty"`
+	Rule    string `json:"rule,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// sarifReporter writes a SARIF 2.1.0 document with a result for each vulnerable wiki, for uploading to GitHub
+// code scanning
+//
+// Only vulnerable wikis become results, whatever -show lets through, and the document is written at Close.
+type sarifReporter struct {
+	w       io.Writer
+	results []sarifResult
+}
+
+func newSARIFReporter(w io.Writer) scan.Reporter {
+	return &sarifReporter{w: w}
+}
+
+func (r *sarifReporter) Report(f scan.Finding) error {
+	v := f.Vulnerability()
+	rule, ok := sarifRules[v]
+	if !ok {
+		return nil
+	}
+
+	index := 0
+	for i, name := range sarifRuleOrder {
+		if name == v {
+			index = i
+		}
+	}
+
+	r.results = append(r.results, sarifResult{
+		RuleID:    rule.ID,
+		RuleIndex: index,
+		Level:     sarifLevels[f.Severity()],
+		Message:   sarifMessage{fmt.Sprintf("%s in %s: %s", rule.ShortDescription.Text, f.Repo, f.URL)},
+		Locations: []sarifLocation{{
+			PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: f.URL},
+				Region:           sarifRegion{StartLine: 1},
+			},
+		}},
+		PartialFingerprints: map[string]string{"gitwikiFinding/v1": f.Fingerprint()},
+		Properties:          sarifResultProps{Account: f.Account, Repo: f.Repo, RepoURL: f.RepoURL, Rule: f.Rule},
+	})
+
+	return nil
+}
+
+// The document can't be written until every finding is in, so there's nothing to flush before Close
+func (r *sarifReporter) Flush() error {
+	return nil
+}
+
+// Writes the document, with an empty list of results when nothing was vulnerable
+func (r *sarifReporter) Close() error {
+	driver := sarifDriver{
+		Name:           "gitwiki",
+		Version:        buildVersion(),
+		InformationURI: "https://github.com/offftherecord/gitwiki",
+	}
+	for _, name := range sarifRuleOrder {
+		driver.Rules = append(driver.Rules, sarifRules[name])
+	}
+
+	results := r.results
+	if results == nil {
+		results = []sarifResult{}
+	}
+	r.results = nil
+
+	enc := json.NewEncoder(r.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	})
+}
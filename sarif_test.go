@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+func TestSARIFDocument(t *testing.T) {
+	findings := []scan.Finding{
+		{Account: "acme", Repo: "acme/open", URL: "https://github.com/acme/open/wiki", Result: scan.ProbeWriteable},
+		{Account: "acme", Repo: "acme/login", URL: "https://github.com/acme/login/wiki", Result: scan.ProbeWriteable, SignedInOnly: true},
+		{Account: "acme", Repo: "acme/new", URL: "https://github.com/acme/new/wiki", Result: scan.ProbeEmpty},
+		{Account: "acme", Repo: "acme/ruled", URL: "https://github.com/acme/ruled/wiki", Result: scan.ProbeRuleMatch, Rule: "defaced"},
+		{Account: "acme", Repo: "acme/locked", URL: "https://github.com/acme/locked/wiki", Result: scan.ProbeRequiresAuth},
+	}
+	var buf bytes.Buffer
+	r := newSARIFReporter(&buf)
+	for _, f := range findings {
+		if err := r.Report(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Schema  string `json:"$schema"`
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				RuleIndex int    `json:"ruleIndex"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("output isn't JSON: %v\n%s", err, buf.String())
+	}
+	if doc.Schema != sarifSchema || doc.Version != "2.1.0" {
+		t.Errorf("document is %q version %q, want SARIF 2.1.0", doc.Schema, doc.Version)
+	}
+	if len(doc.Runs) != 1 {
+		t.Fatalf("document has %d runs, want 1", len(doc.Runs))
+	}
+	run := doc.Runs[0]
+
+	var ids []string
+	for _, rule := range run.Tool.Driver.Rules {
+		ids = append(ids, rule.ID)
+	}
+	sort.Strings(ids)
+	if got, want := strings.Join(ids, ","), "firstpage-wiki,rule-match-wiki,writeable-authenticated-wiki,writeable-wiki"; got != want {
+		t.Errorf("rules = %s, want %s", got, want)
+	}
+
+	// Only the vulnerable findings become results, in the order they were reported
+	want := []struct{ ruleID, level, uri string }{
+		{"writeable-wiki", "error", findings[0].URL},
+		{"writeable-authenticated-wiki", "error", findings[1].URL},
+		{"firstpage-wiki", "warning", findings[2].URL},
+		{"rule-match-wiki", "warning", findings[3].URL},
+	}
+	if len(run.Results) != len(want) {
+		t.Fatalf("document has %d results, want %d:\n%s", len(run.Results), len(want), buf.String())
+	}
+	for i, result := range run.Results {
+		if result.RuleID != want[i].ruleID || result.Level != want[i].level {
+			t.Errorf("result %d is %s at level %s, want %s at %s", i, result.RuleID, result.Level, want[i].ruleID, want[i].level)
+		}
+		if result.RuleIndex < 0 || result.RuleIndex >= len(run.Tool.Driver.Rules) || run.Tool.Driver.Rules[result.RuleIndex].ID != result.RuleID {
+			t.Errorf("result %d has rule index %d, which isn't its rule %s", i, result.RuleIndex, result.RuleID)
+		}
+		if len(result.Locations) != 1 || result.Locations[0].PhysicalLocation.ArtifactLocation.URI != want[i].uri {
+			t.Errorf("result %d is located at %+v, want the wiki %s", i, result.Locations, want[i].uri)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Gets whether an account is an organization or a user, as targetOrg or targetUser
+func (s *Scanner) getAccountType(ctx context.Context, name string) (string, error) {
+	req, err := newAPIRequest(ctx, http.MethodGet, fmt.Sprintf("%s/users/%s", s.APIURL, url.PathEscape(name)), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.getAPIClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", NewHTTPError("fetch account", resp)
+	}
+
+	var account struct {
+		Type string `json:"type"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&account); err != nil {
+		return "", err
+	}
+
+	if account.Type == "Organization" {
+		return targetOrg, nil
+	}
+	return targetUser, nil
+}
+
+// Gets the login of the user the primary token authenticates as, or "" without a token or when it can't be told
+func (s *Scanner) authenticatedLogin(ctx context.Context) string {
+	s.viewer.mu.Lock()
+	defer s.viewer.mu.Unlock()
+
+	if !s.viewer.checked {
+		if token := s.tokens.primary(); token != "" {
+			info, err := s.checkToken(ctx, token)
+			if err != nil {
+				s.logError("warn", "check token", "", "", err)
+				return ""
+			}
+			s.viewer.login = info.Login
+		}
+		s.viewer.checked = true
+	}
+
+	return s.viewer.login
+}
+
+// Gets the URL of the first page of an account's repositories listed as the given type, perPage at a time
+func (s *Scanner) accountListingURL(ctx context.Context, kind, name string, perPage int) string {
+	endpoint := "users"
+	if kind == targetOrg {
+		endpoint = "orgs"
+	}
+
+	u := fmt.Sprintf("%s/%s/%s/repos?per_page=%d", s.APIURL, endpoint, url.PathEscape(name), perPage)
+	// The users API only lists public repositories, so private ones can only be listed for the token's own user
+	if kind == targetUser && s.includesPrivate() {
+		if login := s.authenticatedLogin(ctx); login != "" && strings.EqualFold(login, name) {
+			u = fmt.Sprintf("%s/user/repos?visibility=%s&affiliation=owner&per_page=%d", s.APIURL, s.Visibility, perPage)
+		} else {
+			s.warnf("%s isn't the user the token authenticates as, so only their public repositories can be listed", name)
+		}
+	}
+	// The cap keeps the most recently pushed, rather than the first by name or by when they were created, and
+	// PushedSince can stop at the first page of older ones
+	if s.listedByPush() {
+		u += "&sort=pushed"
+	}
+
+	return u
+}
+
+// Whether listings are sorted with the most recently pushed first, for MaxRepos and PushedSince
+func (s *Scanner) listedByPush() bool {
+	return s.MaxRepos > 0 || !s.PushedSince.IsZero()
+}
+
+// Gets the repositories of an account listed as the given type
+func (s *Scanner) listAccountRepositories(ctx context.Context, kind, name string) ([]Repository, error) {
+	return s.listRepositories(ctx, s.accountListingURL(ctx, kind, name, reposPerPage), s.listedByPush())
+}
+
+// Gets the type to list an account whose type wasn't given as, as targetOrg or targetUser
+//
+// We use /users/ and not /orgs/ because not all Github repositories belong to orgs, but all orgs are users
+// apparently. Only the organizations API lists private repositories though, so then it's worth the call to find
+// out which this is.
+func (s *Scanner) accountListingKind(ctx context.Context, name string) string {
+	if s.includesPrivate() {
+		if detected, err := s.getAccountType(ctx, name); err == nil && detected == targetOrg {
+			return targetOrg
+		}
+	}
+
+	return targetUser
+}
+
+// Gets the other type an account could be listed as
+func otherAccountKind(kind string) string {
+	if kind == targetOrg {
+		return targetUser
+	}
+	return targetOrg
+}
+
+// Gets the repositories of an org: or user: account, with FixAccountType trying again as the account's
+// actual type when the listing isn't found
+func (s *Scanner) getTypedAccountRepositories(ctx context.Context, kind, name string) ([]Repository, error) {
+	repos, err := s.listAccountRepositories(ctx, kind, name)
+
+	var httpErr *HTTPError
+	if !s.FixAccountType || !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+		return repos, err
+	}
+
+	actual, typeErr := s.getAccountType(ctx, name)
+	if typeErr != nil || actual == kind {
+		return repos, err
+	}
+
+	s.logError("warn", "list repositories", name, "", fmt.Errorf("%s is a %s, not a %s, listing it as a %s", name, actual, kind, actual))
+	return s.listAccountRepositories(ctx, actual, name)
+}
@@ -0,0 +1,98 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrivateUserListings(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/user":
+			fmt.Fprint(w, `{"login": "me"}`)
+		case "/user/repos":
+			if q := r.URL.Query(); q.Get("visibility") == VisibilityPublic || q.Get("affiliation") != "owner" {
+				t.Errorf("own repositories were listed with %s", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `[{"name": "secret-plans", "visibility": "private"}, {"name": "blog", "visibility": "public"}]`)
+		case "/users/other/repos":
+			fmt.Fprint(w, `[{"name": "dotfiles", "visibility": "public"}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		visibility string
+		account    string
+		want       []string
+		warns      bool
+	}{
+		{visibility: VisibilityPrivate, account: "Me", want: []string{"secret-plans"}},
+		{visibility: VisibilityAll, account: "me", want: []string{"secret-plans", "blog"}},
+		{visibility: VisibilityPrivate, account: "other", want: nil, warns: true},
+		{visibility: VisibilityAll, account: "other", want: []string{"dotfiles"}, warns: true},
+		{visibility: VisibilityPublic, account: "other", want: []string{"dotfiles"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.visibility+" "+tt.account, func(t *testing.T) {
+			var logs bytes.Buffer
+			s := NewScanner()
+			s.APIURL = srv.URL
+			s.AddTokens("secret")
+			s.Visibility = tt.visibility
+			s.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+			repos, err := s.listAccountRepositories(context.Background(), targetUser, tt.account)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, repo := range repos {
+				names = append(names, repo.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("listed %v, want %v", names, tt.want)
+			}
+			if warned := strings.Contains(logs.String(), "only their public repositories"); warned != tt.warns {
+				t.Errorf("warned = %v, want %v:\n%s", warned, tt.warns, logs.String())
+			}
+		})
+	}
+}
+
+func TestFixAccountType(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/octocat/repos", http.NotFound)
+	mux.HandleFunc("/users/octocat", respond(http.StatusOK, `{"type": "User"}`))
+	mux.HandleFunc("/users/octocat/repos", listing(Repository{Name: "hello-world", URL: "/octocat/hello-world"}))
+	s, _ := fakeGitHub(t, mux)
+	var logs bytes.Buffer
+	s.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+	s.FixAccountType = true
+	repos, err := s.getTypedAccountRepositories(context.Background(), targetOrg, "octocat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 1 || repos[0].Name != "hello-world" {
+		t.Errorf("with FixAccountType, org:octocat listed %v, want the user's repositories", repos)
+	}
+	if !strings.Contains(logs.String(), "octocat is a user, not a org") {
+		t.Errorf("listing the account as its actual type wasn't warned about:\n%s", logs.String())
+	}
+
+	s.FixAccountType = false
+	var httpErr *HTTPError
+	if _, err := s.getTypedAccountRepositories(context.Background(), targetOrg, "octocat"); !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+		t.Errorf("without FixAccountType, org:octocat = %v, want the listing's 404", err)
+	}
+}
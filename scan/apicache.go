@@ -0,0 +1,141 @@
+package scan
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/offftherecord/gitwiki/internal/atomicfile"
+)
+
+// Response headers worth keeping with a cached body, Link being what pagination follows
+var cachedHeaders = []string{"Content-Type", "ETag", "Link"}
+
+// cachedResponse is an API response as stored in the cache directory
+type cachedResponse struct {
+	URL    string      `json:"url"`
+	ETag   string      `json:"etag"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// cacheTransport replays API responses that haven't changed since they were cached
+//
+// Requests for a cached URL go out with If-None-Match, and a 304 back, which doesn't count against the rate
+// limit, gets the cached response instead.
+type cacheTransport struct {
+	base http.RoundTripper
+	dir  string
+	s    *Scanner
+}
+
+// Gets the file a URL's response is cached in
+func (t *cacheTransport) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(t.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (t *cacheTransport) load(url string) *cachedResponse {
+	data, err := os.ReadFile(t.path(url))
+	if err != nil {
+		return nil
+	}
+
+	var c cachedResponse
+	if err := json.Unmarshal(data, &c); err != nil || c.URL != url || c.ETag == "" {
+		return nil
+	}
+
+	return &c
+}
+
+func (t *cacheTransport) save(c *cachedResponse) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	f, err := atomicfile.Create(t.path(c.URL))
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.File.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	return f.Close()
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+
+	url := req.URL.String()
+	cached := t.load(url)
+	if cached != nil {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		drainAndClose(resp)
+		t.s.debugf("API %s unchanged since it was cached", url)
+		return replay(cached, resp), nil
+	case resp.StatusCode == http.StatusOK && resp.Header.Get("ETag") != "":
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		c := &cachedResponse{URL: url, ETag: resp.Header.Get("ETag"), Header: http.Header{}, Body: body}
+		for _, key := range cachedHeaders {
+			if value := resp.Header.Get(key); value != "" {
+				c.Header.Set(key, value)
+			}
+		}
+		if err := t.save(c); err != nil {
+			t.s.logError("warn", "cache API response", "", "", err)
+		}
+	}
+
+	return resp, nil
+}
+
+// Turns a 304 back into the cached 200, with the rate limit headers of the 304
+func replay(cached *cachedResponse, notModified *http.Response) *http.Response {
+	header := cached.Header.Clone()
+	for key, values := range notModified.Header {
+		if strings.HasPrefix(key, "X-Ratelimit-") {
+			header[key] = values
+		}
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         notModified.Proto,
+		ProtoMajor:    notModified.ProtoMajor,
+		ProtoMinor:    notModified.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(cached.Body)),
+		ContentLength: int64(len(cached.Body)),
+		Request:       notModified.Request,
+	}
+}
@@ -0,0 +1,68 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestAPICacheReplaysNotModified(t *testing.T) {
+	var notModified, full int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/acme/repos", func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		page = max(page, 1)
+		etag := fmt.Sprintf(`"page-%d"`, page)
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.Header().Set("X-RateLimit-Remaining", "4999")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		full++
+		w.Header().Set("ETag", etag)
+		if page == 1 {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/users/acme/repos?page=2>; rel="next"`, r.Host))
+		}
+		name := fmt.Sprintf("repo%d", page)
+		listing(Repository{Name: name, URL: "/acme/" + name, HasWiki: true})(w, r)
+	})
+	mux.HandleFunc("/users/acme", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"account"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("ETag", `"account"`)
+		fmt.Fprint(w, `{"type": "Organization"}`)
+	})
+	s, _ := fakeGitHub(t, mux)
+	s.APICacheDir = t.TempDir()
+
+	for run := 1; run <= 2; run++ {
+		kind, err := s.getAccountType(context.Background(), "acme")
+		if err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+		if kind != targetOrg {
+			t.Errorf("run %d: account type = %s, want %s", run, kind, targetOrg)
+		}
+
+		repos, err := s.listAccountRepositories(context.Background(), targetUser, "acme")
+		if err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+		// The cached Link header still has to lead the second run on to page 2
+		if len(repos) != 2 || repos[0].Name != "repo1" || repos[1].Name != "repo2" {
+			t.Errorf("run %d: listed %+v, want both pages", run, repos)
+		}
+	}
+
+	if full != 3 || notModified != 3 {
+		t.Errorf("got %d full responses and %d 304s, want every request in the second run to come back 304", full, notModified)
+	}
+}
@@ -0,0 +1,153 @@
+package scan
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// How long before an installation token expires that it's swapped for a new one
+const appTokenRefreshMargin = 5 * time.Minute
+
+// App is a GitHub App installation to get tokens for, which have much higher rate limits than a user's token
+type App struct {
+	ID             string
+	InstallationID int64
+	Key            *rsa.PrivateKey
+}
+
+// Parses a GitHub App private key, which GitHub hands out as PKCS #1 PEM
+func ParseAppKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM private key found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("not an RSA private key")
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA private key")
+	}
+
+	return key, nil
+}
+
+// Signs the JWT the app authenticates with to get an installation token
+//
+// It's backdated a minute against clock drift, and GitHub turns down ones that last more than ten minutes.
+func (a App) jwt(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": a.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.Key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Gets a new installation token for an app, along with when it expires
+func (s *Scanner) installationToken(ctx context.Context, a App) (string, time.Time, error) {
+	jwt, err := a.jwt(time.Now())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	u := fmt.Sprintf("%s/app/installations/%s/access_tokens", s.APIURL, url.PathEscape(strconv.FormatInt(a.InstallationID, 10)))
+	req, err := newAPIRequest(ctx, http.MethodPost, u, nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	// The token pool would swap in a token of its own, so this goes straight out
+	client := s.NewClient()
+	client.Transport = s.apiTransport()
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", time.Time{}, NewHTTPError("get installation token", resp)
+	}
+
+	var token struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", time.Time{}, err
+	}
+	if token.Token == "" {
+		return "", time.Time{}, errors.New("get installation token: no token in the response")
+	}
+
+	return token.Token, token.ExpiresAt, nil
+}
+
+// Authenticates as a GitHub App's installation, adding its token ahead of any other tokens, and keeps the token
+// fresh for as long as the context lasts
+func (s *Scanner) AuthenticateApp(ctx context.Context, a App) error {
+	token, expires, err := s.installationToken(ctx, a)
+	if err != nil {
+		return err
+	}
+	s.tokens.addFirst(token, true)
+	s.infof("Authenticating as GitHub App %s installation %d, with a token until %s", a.ID, a.InstallationID, expires.Format(time.RFC3339))
+
+	go s.refreshAppToken(ctx, a, token, expires)
+	return nil
+}
+
+// Swaps the installation token for a new one before it expires, trying again a minute later when that fails
+func (s *Scanner) refreshAppToken(ctx context.Context, a App, token string, expires time.Time) {
+	for {
+		if err := sleepContext(ctx, time.Until(expires)-appTokenRefreshMargin); err != nil {
+			return
+		}
+
+		next, nextExpires, err := s.installationToken(ctx, a)
+		if err != nil {
+			s.logError("warn", "refresh installation token", "", "", err)
+			expires = time.Now().Add(appTokenRefreshMargin + time.Minute)
+			continue
+		}
+
+		s.tokens.replace(token, next)
+		s.debugf("Refreshed the GitHub App installation token, now valid until %s", nextExpires.Format(time.RFC3339))
+		token, expires = next, nextExpires
+	}
+}
@@ -0,0 +1,71 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Jitter is how backoff delays are randomized
+type Jitter string
+
+// Jitter strategies for backoff delays
+const (
+	// A random delay between zero and the backoff
+	JitterFull Jitter = "full"
+	// Half the backoff plus a random delay up to the other half
+	JitterEqual Jitter = "equal"
+	// Exactly the backoff
+	JitterNone Jitter = "none"
+)
+
+// Gets a jitter strategy by its name
+func ParseJitter(value string) (Jitter, error) {
+	switch j := Jitter(value); j {
+	case JitterFull, JitterEqual, JitterNone:
+		return j, nil
+	default:
+		return "", fmt.Errorf("jitter must be %s, %s or %s, got %q", JitterFull, JitterEqual, JitterNone, value)
+	}
+}
+
+// Computes how long to wait before retry number attempt (starting at 1), doubling from base up to limit
+//
+// Every retry, and every rate limit wait that doesn't say how long to wait, goes through this, so they all follow
+// Scanner.BackoffJitter. The empty strategy is JitterFull.
+func (j Jitter) Backoff(attempt int, base, limit time.Duration) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < limit; i++ {
+		d *= 2
+	}
+	d = min(d, limit)
+	if d <= 0 {
+		return 0
+	}
+
+	switch j {
+	case JitterNone:
+		return d
+	case JitterEqual:
+		return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	default:
+		return time.Duration(rand.Int63n(int64(d) + 1))
+	}
+}
+
+// Sleeps for d, or until the context is done, in which case it returns the context's error
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
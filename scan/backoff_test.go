@@ -0,0 +1,75 @@
+package scan
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackoffJitterBounds(t *testing.T) {
+	const base, limit = 100 * time.Millisecond, time.Second
+	// The backoff doubles from base on each attempt until it reaches limit
+	ceilings := map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 4: 800 * time.Millisecond, 5: time.Second, 10: time.Second}
+
+	tests := []struct {
+		jitter string
+		floor  func(d time.Duration) time.Duration
+	}{
+		{jitter: "none", floor: func(d time.Duration) time.Duration { return d }},
+		{jitter: "equal", floor: func(d time.Duration) time.Duration { return d / 2 }},
+		{jitter: "full", floor: func(d time.Duration) time.Duration { return 0 }},
+	}
+	for _, tt := range tests {
+		jitter, err := ParseJitter(tt.jitter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for attempt, ceiling := range ceilings {
+			lowest, highest := ceiling, time.Duration(0)
+			for i := 0; i < 1000; i++ {
+				d := jitter.Backoff(attempt, base, limit)
+				if d < tt.floor(ceiling) || d > ceiling {
+					t.Fatalf("%s jitter: attempt %d waited %s, want between %s and %s", tt.jitter, attempt, d, tt.floor(ceiling), ceiling)
+				}
+				lowest, highest = min(lowest, d), max(highest, d)
+			}
+			// Jittered waits should spread across most of their range rather than sitting at one end
+			if spread := ceiling - tt.floor(ceiling); spread > 0 && highest-lowest < spread/2 {
+				t.Errorf("%s jitter: attempt %d only waited between %s and %s", tt.jitter, attempt, lowest, highest)
+			}
+		}
+	}
+
+	if _, err := ParseJitter("random"); err == nil {
+		t.Error("an unknown jitter strategy was accepted")
+	}
+	if d := JitterFull.Backoff(3, 0, limit); d != 0 {
+		t.Errorf("backoff from a zero base = %s, want 0", d)
+	}
+}
+
+func TestSecondaryRateLimitBacksOff(t *testing.T) {
+	limited := func(header http.Header) *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusForbidden,
+			Header:     header,
+			Body:       io.NopCloser(strings.NewReader(`{"message": "You have exceeded a secondary rate limit."}`)),
+		}
+	}
+	for attempt, want := range map[int]time.Duration{1: 2 * time.Minute, 2: 3 * time.Minute, 3: 5 * time.Minute, 5: 5 * time.Minute} {
+		if wait, ok := secondaryRateLimit(limited(http.Header{}), attempt, JitterNone); !ok || wait != want {
+			t.Errorf("attempt %d waited %s (%t), want %s", attempt, wait, ok, want)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		if wait, _ := secondaryRateLimit(limited(http.Header{}), 1, JitterFull); wait < secondaryRateLimitWait {
+			t.Fatalf("jittered wait %s is under the minute GitHub asks for", wait)
+		}
+	}
+	if wait, ok := secondaryRateLimit(limited(http.Header{"Retry-After": {"7"}}), 3, JitterFull); !ok || wait != 7*time.Second {
+		t.Errorf("wait with a Retry-After = %s (%t), want the 7s it says", wait, ok)
+	}
+}
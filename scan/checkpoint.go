@@ -0,0 +1,110 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// checkpointEntry is a repository a scan finished checking, one JSON line each in the checkpoint file
+type checkpointEntry struct {
+	RepoURL string `json:"repo_url"`
+}
+
+// Checkpoint records which repositories an interrupted scan already checked, so running it again picks up where
+// it left off
+//
+// Entries are appended a line at a time as repositories are checked. A scan that dies partway through writing
+// one leaves a torn last line, which is skipped when the file is loaded.
+type Checkpoint struct {
+	path string
+	f    *os.File
+	done map[string]bool
+}
+
+// Opens the checkpoint at path, loading what it already has and creating it if it doesn't exist
+func OpenCheckpoint(path string) (*Checkpoint, error) {
+	c := &Checkpoint{path: path, done: make(map[string]bool)}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var entry checkpointEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry.RepoURL != "" {
+			c.done[checkpointKey(entry.RepoURL)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	c.f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	// A torn line gets finished off, so the next entry starts on a line of its own
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		if _, err := c.f.WriteString("\n"); err != nil {
+			c.f.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func checkpointKey(repoURL string) string {
+	return strings.ToLower(strings.TrimSuffix(repoURL, "/"))
+}
+
+// How many repositories the checkpoint already has
+func (c *Checkpoint) Len() int {
+	if c == nil {
+		return 0
+	}
+
+	return len(c.done)
+}
+
+// Whether a repository was checked by an earlier run of the scan, not counting the ones this run checked
+func (c *Checkpoint) Done(repo Repository) bool {
+	return c != nil && c.done[checkpointKey(repo.URL)]
+}
+
+// Adds a checked repository to the checkpoint
+func (c *Checkpoint) Record(repo Repository) error {
+	if c == nil {
+		return nil
+	}
+
+	line, err := json.Marshal(checkpointEntry{RepoURL: repo.URL})
+	if err != nil {
+		return err
+	}
+	_, err = c.f.Write(append(line, '\n'))
+	return err
+}
+
+// Closes the checkpoint, deleting it when the scan finished so the next one starts from scratch
+func (c *Checkpoint) Close(finished bool) error {
+	if c == nil {
+		return nil
+	}
+
+	if err := c.f.Close(); err != nil {
+		return err
+	}
+	if finished {
+		return os.Remove(c.path)
+	}
+
+	return nil
+}
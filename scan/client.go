@@ -0,0 +1,118 @@
+package scan
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+)
+
+// Gets an HTTP client that doesn't follow redirects, on the scanner's transport so it shares its connections,
+// proxies and TLS settings, and sends UserAgent
+func (s *Scanner) NewClient() *http.Client {
+	client := &http.Client{
+		Transport: &userAgentTransport{base: s.transport, userAgent: s.UserAgent},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return client
+}
+
+// headerTransport adds a fixed set of headers to every request before handing it to the base transport
+type headerTransport struct {
+	base    http.RoundTripper
+	headers http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	for key, values := range t.headers {
+		req.Header.Del(key)
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// userAgentTransport sends userAgent on requests that don't already have a User-Agent, such as one from
+// BrowserHeaders or APIHeaders
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.userAgent == "" || req.Header.Get("User-Agent") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+
+	return t.base.RoundTrip(req)
+}
+
+// Gets an HTTP client for the GitHub API that doesn't follow redirects, authenticates with the token pool and
+// adds any custom API headers
+func (s *Scanner) getAPIClient() *http.Client {
+	client := s.NewClient()
+	client.Transport = &tokenTransport{base: s.apiTransport(), s: s}
+	if s.APICacheDir != "" {
+		client.Transport = &cacheTransport{base: client.Transport, dir: s.APICacheDir, s: s}
+	}
+
+	return client
+}
+
+// Gets the transport API requests go out on before a token is added, with any APIHeaders
+func (s *Scanner) apiTransport() http.RoundTripper {
+	base := &userAgentTransport{base: s.transport, userAgent: s.UserAgent}
+	if len(s.APIHeaders) > 0 {
+		return &headerTransport{base: base, headers: s.APIHeaders}
+	}
+
+	return base
+}
+
+// Realistic browser identities to pick from, each a User-Agent with a matching Accept-Language
+var browserProfiles = []struct {
+	userAgent      string
+	acceptLanguage string
+}{
+	{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", "en-US,en;q=0.9"},
+	{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15", "en-US,en;q=0.9"},
+	{"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:125.0) Gecko/20100101 Firefox/125.0", "en-US,en;q=0.5"},
+	{"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", "en-GB,en;q=0.9"},
+	{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:125.0) Gecko/20100101 Firefox/125.0", "en-US,en;q=0.5"},
+}
+
+// Adds the headers a browser would send on a page load, from a randomly picked browser
+func setBrowserHeaders(req *http.Request) {
+	profile := browserProfiles[rand.Intn(len(browserProfiles))]
+	req.Header.Set("User-Agent", profile.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	req.Header.Set("Accept-Language", profile.acceptLanguage)
+	req.Header.Set("Upgrade-Insecure-Requests", "1")
+	req.Header.Set("Sec-Fetch-Dest", "document")
+	req.Header.Set("Sec-Fetch-Mode", "navigate")
+	req.Header.Set("Sec-Fetch-Site", "none")
+}
+
+// Builds a wiki probe request
+func (s *Scanner) newProbeRequest(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.BrowserHeaders {
+		setBrowserHeaders(req)
+	}
+	s.setProbeAuth(req)
+
+	return req, nil
+}
@@ -0,0 +1,30 @@
+package scan
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Waits for this request's turn, Delay and up to DelayJitter after the last one's, or until the context is done,
+// in which case it returns the context's error
+func (s *Scanner) politeDelay(ctx context.Context) error {
+	d := s.Delay
+	if s.DelayJitter > 0 {
+		d += time.Duration(rand.Int63n(int64(s.DelayJitter) + 1))
+	}
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	s.delayGate.mu.Lock()
+	now := time.Now()
+	turn := s.delayGate.next
+	if turn.Before(now) {
+		turn = now
+	}
+	s.delayGate.next = turn.Add(d)
+	s.delayGate.mu.Unlock()
+
+	return sleepContext(ctx, time.Until(turn))
+}
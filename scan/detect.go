@@ -0,0 +1,337 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// wikiCheck is what's known about a wiki as it goes through the detection stages
+type wikiCheck struct {
+	s *Scanner
+	// Client the stages' requests go out on, which doesn't follow redirects
+	client *http.Client
+	repo   Repository
+	probe  Probe
+	// The landing page body, once the landing stage has fetched it
+	body string
+	// Whether the git stage found pages in the wiki's repository
+	hasPages bool
+}
+
+// Stage looks at a wiki and either decides its result, or leaves it to the stages after it
+type Stage struct {
+	name string
+	// Whether the stage needs the landing page body
+	needsBody bool
+	// Returns true once the result is decided, with the error that goes with it
+	run func(ctx context.Context, c *wikiCheck) (bool, error)
+}
+
+// Gets the stage's name, as ParseStages takes it
+func (s Stage) Name() string {
+	return s.name
+}
+
+// All detection stages, in their default order
+var allStages = []Stage{
+	{name: "has-wiki", run: stageHasWiki},
+	{name: "url", run: stageURL},
+	{name: "landing", run: stageLanding},
+	{name: "min-size", needsBody: true, run: stageMinSize},
+	{name: "firstpage", needsBody: true, run: stageFirstPage},
+	{name: "rules", needsBody: true, run: stageRules},
+	{name: "new-link", needsBody: true, run: stageNewLink},
+	{name: "writeable", run: stageWriteable},
+}
+
+// Stage that goes by the wiki's git repository rather than its landing page
+var gitStage = Stage{name: "git", run: stageGit}
+
+// Gets all detection stages, in their default order
+func AllStages() []Stage {
+	return append([]Stage(nil), allStages...)
+}
+
+// Adds the git stage to stages, ahead of the first one that fetches a page from the wiki, so those only run
+// when it can't decide
+func WithGitStage(stages []Stage) []Stage {
+	var out []Stage
+	added := false
+	for _, s := range stages {
+		if !added && (s.name == "landing" || s.name == "writeable") {
+			out = append(out, gitStage)
+			added = true
+		}
+		out = append(out, s)
+	}
+	if !added {
+		out = append(out, gitStage)
+	}
+
+	return out
+}
+
+// Gets the names of all detection stages, in their default order
+func StageNames() []string {
+	var names []string
+	for _, s := range allStages {
+		names = append(names, s.name)
+	}
+
+	return names
+}
+
+// Parses a comma separated list of stage names into the stages to run, in that order
+func ParseStages(value string) ([]Stage, error) {
+	byName := make(map[string]Stage)
+	for _, s := range allStages {
+		byName[s.name] = s
+	}
+
+	var stages []Stage
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		s, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown stage %q, must be one of %s", name, strings.Join(StageNames(), ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("stage %q is listed twice", name)
+		}
+		if s.needsBody && !seen["landing"] {
+			return nil, fmt.Errorf("stage %q needs the landing page, so it must come after the landing stage", name)
+		}
+		seen[name] = true
+		stages = append(stages, s)
+	}
+
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("no stages given")
+	}
+
+	return stages, nil
+}
+
+// Runs the detection stages over a wiki until one of them decides its result
+func (s *Scanner) runStages(ctx context.Context, client *http.Client, stages []Stage, repo Repository) (Probe, error) {
+	c := &wikiCheck{s: s, client: client, repo: repo, probe: Probe{URL: repo.URL}}
+	for _, stage := range stages {
+		if done, err := stage.run(ctx, c); done {
+			s.debugf("Stage %s classified %s as %s", stage.name, repo.URL, c.probe.Result)
+			return c.probe, err
+		}
+	}
+
+	c.probe.Result = ProbeUnexpected
+	return c.probe, fmt.Errorf("no detection stage decided the result")
+}
+
+// Repositories without a wiki have nothing to probe
+func stageHasWiki(ctx context.Context, c *wikiCheck) (bool, error) {
+	if c.repo.HasWiki {
+		return false, nil
+	}
+
+	c.probe.Result = ProbeNoWiki
+	return true, nil
+}
+
+// With StrictURLs, URLs that aren't a repository on a provider host aren't probed
+func stageURL(ctx context.Context, c *wikiCheck) (bool, error) {
+	if !c.s.StrictURLs {
+		return false, nil
+	}
+
+	if err := validateRepoURL(c.repo.URL, c.s.ProviderHosts); err != nil {
+		c.probe.Result = ProbeInvalidURL
+		return true, fmt.Errorf("invalid repository URL %q: %w", c.repo.URL, err)
+	}
+
+	return false, nil
+}
+
+// Fetches the landing page, which decides the result unless it's there
+func stageLanding(ctx context.Context, c *wikiCheck) (bool, error) {
+	c.probe.URL = c.repo.URL + "/wiki"
+
+	resp, err := c.s.probeLanding(ctx, c.client, c.probe.URL)
+	if err != nil {
+		c.probe.Result = ProbeError
+		return true, err
+	}
+	defer drainAndClose(resp)
+	c.probe.Status = resp.StatusCode
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		c.s.recordRedirects(ctx, c.client, &c.probe, resp)
+		c.probe.Result, err = classifyStatus(resp)
+		// The listing said there's a wiki, so something above the repository turned it off
+		if c.probe.Result == ProbeDisabled && isRepoRedirect(resp, c.repo.URL) {
+			c.probe.Result = ProbeAdminDisabled
+		}
+		return true, err
+	}
+
+	body, err := c.s.readLanding(resp.Body)
+	if err != nil {
+		c.probe.Result = ProbeError
+		return true, fmt.Errorf("reading response body: %w", err)
+	}
+	c.body = body
+
+	return false, nil
+}
+
+// A stripped down page from a proxy or filter would fail the marker check and send us on to the writeable
+// probe, so don't draw conclusions from it
+func stageMinSize(ctx context.Context, c *wikiCheck) (bool, error) {
+	if len(c.body) >= c.s.MinBodySize {
+		return false, nil
+	}
+
+	c.probe.Result = ProbeUnexpected
+	return true, fmt.Errorf("landing page is only %d bytes, expected at least %d", len(c.body), c.s.MinBodySize)
+}
+
+// Check if wiki is writable but doesn't have a first page yet
+func stageFirstPage(ctx context.Context, c *wikiCheck) (bool, error) {
+	if c.s.NoFirstPage || !c.s.hasFirstPageMarker(c.body) {
+		return false, nil
+	}
+
+	// A marker on a wiki that has pages is a false positive, which the writeable probe can sort out
+	if c.s.VerifyEmpty {
+		hasPages, err := c.s.wikiHasPages(ctx, c.client, c.repo.URL)
+		if err != nil {
+			c.s.debugf("Couldn't check %s for wiki pages, going by the marker: %v", c.repo.URL, err)
+		} else if hasPages {
+			return false, nil
+		}
+	}
+
+	c.probe.Result = ProbeEmpty
+	return true, nil
+}
+
+// With the git stage, a wiki whose git repository has no branches has no pages, while one with pages goes straight
+// to the writeable probe, so the landing page isn't looked at
+//
+// When the repository can't be checked, which is what happens without a token as GitHub asks for one for
+// repositories it can't find, the wiki is passed on to the landing page stages instead.
+func stageGit(ctx context.Context, c *wikiCheck) (bool, error) {
+	hasPages, err := c.s.wikiHasPages(ctx, c.client, c.repo.URL)
+	if err != nil {
+		c.s.debugf("Couldn't check %s's wiki repository, checking its landing page instead: %v", c.repo.URL, err)
+		return false, nil
+	}
+
+	if !hasPages {
+		c.probe.URL = c.repo.URL + "/wiki"
+		c.probe.Result = ProbeEmpty
+		return true, nil
+	}
+
+	c.hasPages = true
+	return stageWriteable(ctx, c)
+}
+
+// With FirstPageOnly, the landing page only links to the new page form when we're allowed to use it
+func stageNewLink(ctx context.Context, c *wikiCheck) (bool, error) {
+	if !c.s.FirstPageOnly {
+		return false, nil
+	}
+
+	if strings.Contains(c.body, "/wiki/_new") {
+		c.probe.Result = ProbeWriteable
+	} else {
+		c.probe.Result = ProbeReadOnly
+	}
+
+	return true, nil
+}
+
+// Gets the name of a page the wiki shouldn't have, TestPage when it's set
+//
+// A fixed name would miss wikis that happen to have a page by that name, and could be allowlisted.
+func (s *Scanner) testPageName() string {
+	if s.TestPage != "" {
+		return s.TestPage
+	}
+
+	return fmt.Sprintf("zz-%016x", rand.Uint64())
+}
+
+// If we can go to github.com/<repo>/wiki/<somenewpage> that means we can edit an existing wiki
+//
+// Findings carry the wiki's URL rather than the page's, so a random page name doesn't change the URL and
+// fingerprint a wiki is reported with from one run to the next.
+func stageWriteable(ctx context.Context, c *wikiCheck) (bool, error) {
+	c.probe.URL = c.repo.URL + "/wiki"
+	page := c.probe.URL + "/" + url.PathEscape(c.s.testPageName())
+	c.s.debugf("Probing %s for writeable wiki %s", page, c.probe.URL)
+
+	resp, err := c.s.probe(ctx, c.client, page)
+	if err != nil {
+		c.probe.Result = ProbeError
+		return true, err
+	}
+	defer drainAndClose(resp)
+	c.probe.Status = resp.StatusCode
+
+	if resp.StatusCode != http.StatusOK {
+		c.s.recordRedirects(ctx, c.client, &c.probe, resp)
+
+		// A redirect to log in means the wiki is protected, a 404 or any other redirect that the page is missing
+		// and can't be created by us. Disabled only makes sense for the wiki itself, so a redirect away from a page
+		// we can't create isn't that.
+		c.probe.Result, err = classifyStatus(resp)
+		if c.probe.Result == ProbeDisabled {
+			c.probe.Result = ProbeSoftNotFound
+		}
+
+		// The landing page had content, so the wiki is in use, just not editable by us
+		if c.s.ReportReadable && (c.body != "" || c.hasPages) && (c.probe.Result == ProbeSoftNotFound || c.probe.Result == ProbeRequiresAuth) {
+			c.probe.Result = ProbeReadable
+		}
+		return true, err
+	}
+
+	c.probe.Result = ProbeWriteable
+	checkSignedInOnly(ctx, c, page)
+	return true, nil
+}
+
+// When the writeable probe went out with the token, probes the page again without it to tell a wiki anyone can
+// write to from one that any signed in user can
+//
+// The result stands either way, so a probe that fails only leaves the wiki unmarked.
+func checkSignedInOnly(ctx context.Context, c *wikiCheck, page string) {
+	u, err := url.Parse(page)
+	if err != nil || c.s.probeToken(u) == "" {
+		return
+	}
+
+	req, err := c.s.newProbeRequest(ctx, page)
+	if err != nil {
+		return
+	}
+	req.Header.Del("Authorization")
+
+	resp, err := c.s.doProbeWithRetries(c.client, req)
+	if err != nil {
+		c.s.warnf("Couldn't check whether %s can be written to logged out: %v", c.probe.URL, err)
+		return
+	}
+	defer drainAndClose(resp)
+
+	c.probe.SignedInOnly = resp.StatusCode != http.StatusOK
+}
@@ -0,0 +1,443 @@
+package scan
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Serves a wiki's landing page and the writeable probe's test page from handlers, with any other path a 404
+func wikiServer(t *testing.T, landing, page http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	if landing != nil {
+		mux.HandleFunc("/o/r/wiki", landing)
+	}
+	if page != nil {
+		mux.HandleFunc("/o/r/wiki/"+testPage, page)
+	}
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func respond(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+// Page the writeable probe asks for in tests, which wikiServer serves
+const testPage = "gitwiki-test-page"
+
+// Gets a scanner that asks for testPage and doesn't retry probes
+func newTestScanner() *Scanner {
+	s := NewScanner()
+	s.TestPage = testPage
+	s.ProbeRetries = 0
+
+	return s
+}
+
+// Checks a repository's wiki with every stage of s
+func checkAll(s *Scanner, repo Repository) (Probe, error) {
+	return s.runStages(context.Background(), s.NewClient(), s.Stages, repo)
+}
+
+// A landing page big enough to be a wiki's, with pages on it and nothing offering to create the first one
+var populatedWikiBody = strings.Repeat("<p>Welcome to the wiki</p>\n", 40)
+
+func TestRunStagesClassification(t *testing.T) {
+	emptyWiki := strings.Repeat("<p>Nothing here</p>\n", 40) + WikiFirstPageMarker
+
+	tests := []struct {
+		name    string
+		noWiki  bool
+		landing http.HandlerFunc
+		page    http.HandlerFunc
+		want    ProbeResult
+		wantErr bool
+	}{
+		{name: "no wiki", noWiki: true, want: ProbeNoWiki},
+		{name: "first page marker", landing: respond(http.StatusOK, emptyWiki), want: ProbeEmpty},
+		{name: "test page opens", landing: respond(http.StatusOK, populatedWikiBody), page: respond(http.StatusOK, "edit"), want: ProbeWriteable},
+		{name: "login redirect", landing: respond(http.StatusOK, populatedWikiBody), page: http.RedirectHandler("/login?return_to=x", http.StatusFound).ServeHTTP, want: ProbeRequiresAuth},
+		{name: "sso redirect", landing: respond(http.StatusOK, populatedWikiBody), page: http.RedirectHandler("/orgs/o/sso?return_to=x", http.StatusFound).ServeHTTP, want: ProbeRequiresAuth},
+		{name: "test page redirected away", landing: respond(http.StatusOK, populatedWikiBody), page: http.RedirectHandler("/o/r/wiki", http.StatusFound).ServeHTTP, want: ProbeSoftNotFound},
+		{name: "test page not found", landing: respond(http.StatusOK, populatedWikiBody), want: ProbeSoftNotFound},
+		{name: "wiki not found", want: ProbeSoftNotFound},
+		{name: "throttled", landing: respond(http.StatusTooManyRequests, ""), want: ProbeThrottled, wantErr: true},
+		{name: "server error", landing: respond(http.StatusInternalServerError, ""), want: ProbeError, wantErr: true},
+		{name: "redirect away", landing: http.RedirectHandler("/elsewhere", http.StatusFound).ServeHTTP, want: ProbeDisabled},
+		{name: "redirect to repository", landing: http.RedirectHandler("/o/r", http.StatusFound).ServeHTTP, want: ProbeAdminDisabled},
+		{name: "page too small", landing: respond(http.StatusOK, "tiny"), want: ProbeUnexpected, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := wikiServer(t, tt.landing, tt.page)
+			repo := Repository{Name: "o/r", URL: srv.URL + "/o/r", HasWiki: !tt.noWiki}
+
+			p, err := checkAll(newTestScanner(), repo)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %t", err, tt.wantErr)
+			}
+			if p.Result != tt.want {
+				t.Fatalf("result = %s, want %s", p.Result, tt.want)
+			}
+			if !tt.noWiki && p.URL != repo.URL+"/wiki" {
+				t.Errorf("URL = %s, want %s/wiki", p.URL, repo.URL)
+			}
+		})
+	}
+}
+
+func TestWriteableFindingIsStable(t *testing.T) {
+	var mu sync.Mutex
+	var pages []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/o/r/wiki/") {
+			mu.Lock()
+			pages = append(pages, r.URL.Path)
+			mu.Unlock()
+		}
+		w.Write([]byte("page"))
+	}))
+	defer srv.Close()
+
+	s := NewScanner()
+	s.Stages = []Stage{{name: "writeable", run: stageWriteable}}
+	repo := Repository{Name: "o/r", URL: srv.URL + "/o/r", HasWiki: true}
+	probeTwice := func() {
+		t.Helper()
+
+		var fingerprints []string
+		for i := 0; i < 2; i++ {
+			p, err := checkAll(s, repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Result != ProbeWriteable || p.URL != repo.URL+"/wiki" {
+				t.Fatalf("probe = %s %s, want writeable %s/wiki", p.Result, p.URL, repo.URL)
+			}
+			f := Finding{Account: "org:o", Repo: repo.Name, URL: p.URL, Result: p.Result}
+			fingerprints = append(fingerprints, f.Fingerprint())
+		}
+		if fingerprints[0] != fingerprints[1] {
+			t.Fatalf("fingerprints differ between runs: %s", strings.Join(fingerprints, ", "))
+		}
+	}
+
+	// Each run asks for a new random page, which mustn't change what's reported
+	probeTwice()
+	random := regexp.MustCompile(`^/o/r/wiki/zz-[0-9a-f]{16}$`)
+	if len(pages) != 2 || !random.MatchString(pages[0]) || !random.MatchString(pages[1]) {
+		t.Fatalf("test pages = %q, want two random zz- pages", pages)
+	}
+	if pages[0] == pages[1] {
+		t.Errorf("both runs asked for the same test page %s", pages[0])
+	}
+
+	// TestPage asks for the same page every time
+	s.TestPage = "gitwiki-pinned"
+	pages = nil
+	probeTwice()
+	if len(pages) != 2 || pages[0] != "/o/r/wiki/gitwiki-pinned" || pages[1] != pages[0] {
+		t.Errorf("test pages with TestPage = %q, want /o/r/wiki/gitwiki-pinned both times", pages)
+	}
+}
+
+func TestFirstPageOnlySkipsWriteableProbe(t *testing.T) {
+	tests := []struct {
+		name    string
+		landing string
+		want    ProbeResult
+	}{
+		{name: "new page link", landing: populatedWikiBody + `<a href="/o/r/wiki/_new">New page</a>`, want: ProbeWriteable},
+		{name: "no new page link", landing: populatedWikiBody, want: ProbeReadOnly},
+		{name: "first page marker", landing: populatedWikiBody + WikiFirstPageMarker, want: ProbeEmpty},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requested := false
+			srv := wikiServer(t, respond(http.StatusOK, tt.landing), func(w http.ResponseWriter, r *http.Request) {
+				requested = true
+			})
+			repo := Repository{Name: "o/r", URL: srv.URL + "/o/r", HasWiki: true}
+			s := newTestScanner()
+			s.FirstPageOnly = true
+
+			p, err := checkAll(s, repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Result != tt.want {
+				t.Errorf("result = %s, want %s", p.Result, tt.want)
+			}
+			if requested {
+				t.Error("the writeable probe's test page was requested")
+			}
+		})
+	}
+}
+
+func TestNoFirstPageSkipsMarker(t *testing.T) {
+	emptyWiki := strings.Repeat("<p>Nothing here</p>\n", 40) + WikiFirstPageMarker
+	for _, tt := range []struct {
+		noFirstPage bool
+		want        ProbeResult
+		probed      bool
+	}{
+		{noFirstPage: false, want: ProbeEmpty},
+		{noFirstPage: true, want: ProbeRequiresAuth, probed: true},
+	} {
+		requested := false
+		srv := wikiServer(t, respond(http.StatusOK, emptyWiki), func(w http.ResponseWriter, r *http.Request) {
+			requested = true
+			http.Redirect(w, r, "/login", http.StatusFound)
+		})
+		repo := Repository{Name: "o/r", URL: srv.URL + "/o/r", HasWiki: true}
+		s := newTestScanner()
+		s.NoFirstPage = tt.noFirstPage
+
+		p, err := checkAll(s, repo)
+		if err != nil || p.Result != tt.want || requested != tt.probed {
+			t.Errorf("with NoFirstPage %t: result = %s (%v), writeable probe ran %t, want %s with it ran %t",
+				tt.noFirstPage, p.Result, err, requested, tt.want, tt.probed)
+		}
+	}
+}
+
+func TestVerifyEmpty(t *testing.T) {
+	emptyWiki := strings.Repeat("<p>Nothing here</p>\n", 40) + WikiFirstPageMarker
+	tests := []struct {
+		name string
+		refs http.HandlerFunc
+		want ProbeResult
+	}{
+		{name: "no wiki repository", want: ProbeEmpty},
+		{name: "no branches", refs: respond(http.StatusOK, "001e# service=git-upload-pack\n0000"), want: ProbeEmpty},
+		{name: "has pages", refs: respond(http.StatusOK, "001e# service=git-upload-pack\n0000003f0123456789abcdef0123456789abcdef01234567 refs/heads/master\n0000"), want: ProbeRequiresAuth},
+		{name: "can't check", refs: respond(http.StatusForbidden, ""), want: ProbeEmpty},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/o/r/wiki", respond(http.StatusOK, emptyWiki))
+			mux.Handle("/o/r/wiki/"+testPage, http.RedirectHandler("/login", http.StatusFound))
+			if tt.refs != nil {
+				mux.HandleFunc("/o/r.wiki.git/info/refs", tt.refs)
+			}
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+			repo := Repository{Name: "o/r", URL: srv.URL + "/o/r", HasWiki: true}
+			s := newTestScanner()
+			s.VerifyEmpty = true
+
+			p, err := checkAll(s, repo)
+			if err != nil || p.Result != tt.want {
+				t.Errorf("result = %s (%v), want %s", p.Result, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReportReadable(t *testing.T) {
+	tests := []struct {
+		name     string
+		readable bool
+		page     http.HandlerFunc
+		want     ProbeResult
+	}{
+		{name: "test page not found", readable: true, want: ProbeReadable},
+		{name: "login redirect", readable: true, page: http.RedirectHandler("/login", http.StatusFound).ServeHTTP, want: ProbeReadable},
+		{name: "test page opens", readable: true, page: respond(http.StatusOK, "edit"), want: ProbeWriteable},
+		{name: "not reported", want: ProbeSoftNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := wikiServer(t, respond(http.StatusOK, populatedWikiBody), tt.page)
+			repo := Repository{Name: "o/r", URL: srv.URL + "/o/r", HasWiki: true}
+			s := newTestScanner()
+			s.ReportReadable = tt.readable
+
+			p, err := checkAll(s, repo)
+			if err != nil || p.Result != tt.want {
+				t.Errorf("result = %s (%v), want %s", p.Result, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinBodySize(t *testing.T) {
+	tests := []struct {
+		name    string
+		min     int
+		size    int
+		skipped bool
+	}{
+		{name: "empty body", min: 512, size: 0, skipped: true},
+		{name: "just under", min: 512, size: 511, skipped: true},
+		{name: "at the minimum", min: 512, size: 512},
+		{name: "lowered minimum", min: 16, size: 100},
+		{name: "raised minimum", min: 4096, size: 1000, skipped: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requested := false
+			srv := wikiServer(t, respond(http.StatusOK, strings.Repeat("x", tt.size)), func(w http.ResponseWriter, r *http.Request) {
+				requested = true
+				http.Redirect(w, r, "/login", http.StatusFound)
+			})
+			repo := Repository{Name: "o/r", URL: srv.URL + "/o/r", HasWiki: true}
+			s := newTestScanner()
+			s.MinBodySize = tt.min
+
+			p, err := checkAll(s, repo)
+			if tt.skipped {
+				if p.Result != ProbeUnexpected || err == nil {
+					t.Errorf("result = %s (%v), want %s with a warning", p.Result, err, ProbeUnexpected)
+				}
+				if requested {
+					t.Error("the writeable probe ran on a page that was too small")
+				}
+				return
+			}
+			if err != nil || p.Result != ProbeRequiresAuth || !requested {
+				t.Errorf("result = %s (%v), writeable probe ran %t, want %s from the writeable probe", p.Result, err, requested, ProbeRequiresAuth)
+			}
+		})
+	}
+}
+
+func TestRangeBytesReadsOnlyTheHead(t *testing.T) {
+	s := newTestScanner()
+	s.RangeBytes = 1024
+
+	// The marker is in the first kilobyte, with far more page after it than the range asks for
+	page := strings.Repeat("<p>Nothing here</p>\n", 40) + WikiFirstPageMarker + strings.Repeat("<p>footer</p>\n", 1<<16)
+	var ranges []string
+	srv := wikiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "wiki.html", time.Time{}, strings.NewReader(page))
+	}, nil)
+	repo := Repository{Name: "o/r", URL: srv.URL + "/o/r", HasWiki: true}
+
+	c := &wikiCheck{s: s, client: s.NewClient(), repo: repo}
+	if done, err := stageLanding(context.Background(), c); done || err != nil {
+		t.Fatalf("landing stage decided %s (%v), want it to pass the page on", c.probe.Result, err)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=0-1023" {
+		t.Errorf("landing page was requested with ranges %q, want bytes=0-1023", ranges)
+	}
+	if c.probe.Status != http.StatusPartialContent || len(c.body) != s.RangeBytes {
+		t.Errorf("got a %d with %d bytes, want a 206 with the %d asked for", c.probe.Status, len(c.body), s.RangeBytes)
+	}
+
+	p, err := checkAll(s, repo)
+	if err != nil || p.Result != ProbeEmpty {
+		t.Errorf("result = %s (%v), want %s from the marker in the range", p.Result, err, ProbeEmpty)
+	}
+}
+
+func TestRunStagesShortCircuits(t *testing.T) {
+	var ran []string
+	stage := func(name string, decides bool) Stage {
+		return Stage{name: name, run: func(ctx context.Context, c *wikiCheck) (bool, error) {
+			ran = append(ran, name)
+			if decides {
+				c.probe.Result = ProbeReadOnly
+			}
+			return decides, nil
+		}}
+	}
+
+	s := NewScanner()
+	s.Stages = []Stage{stage("first", false), stage("second", true), stage("third", true)}
+	p, err := checkAll(s, Repository{URL: "https://github.com/o/r"})
+	if err != nil || p.Result != ProbeReadOnly {
+		t.Errorf("result = %s (%v), want the second stage's %s", p.Result, err, ProbeReadOnly)
+	}
+	if strings.Join(ran, ",") != "first,second" {
+		t.Errorf("ran stages %v, want the first two only", ran)
+	}
+
+	ran = nil
+	s.Stages = s.Stages[:1]
+	p, err = checkAll(s, Repository{URL: "https://github.com/o/r"})
+	if err == nil || p.Result != ProbeUnexpected {
+		t.Errorf("result when no stage decides = %s (%v), want %s with an error", p.Result, err, ProbeUnexpected)
+	}
+}
+
+func TestParseStages(t *testing.T) {
+	stages, err := ParseStages("has-wiki, landing,writeable,firstpage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, stage := range stages {
+		names = append(names, stage.Name())
+	}
+	if strings.Join(names, ",") != "has-wiki,landing,writeable,firstpage" {
+		t.Errorf("parsed stages %v, want them in the order given", names)
+	}
+
+	for _, value := range []string{"", "landing,bogus", "landing,landing", "firstpage,landing"} {
+		if _, err := ParseStages(value); err == nil {
+			t.Errorf("ParseStages(%q) succeeded", value)
+		}
+	}
+}
+
+func TestDisabledStagesAreSkipped(t *testing.T) {
+	// Without the firstpage stage an empty wiki isn't reported as empty, and goes on to the writeable probe
+	emptyWiki := strings.Repeat("<p>Nothing here</p>\n", 40) + WikiFirstPageMarker
+	requested := false
+	srv := wikiServer(t, respond(http.StatusOK, emptyWiki), func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+		http.Redirect(w, r, "/login", http.StatusFound)
+	})
+	repo := Repository{Name: "o/r", URL: srv.URL + "/o/r", HasWiki: true}
+
+	stages, err := ParseStages("has-wiki,landing,writeable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestScanner()
+	s.Stages = stages
+	p, err := checkAll(s, repo)
+	if err != nil || p.Result != ProbeRequiresAuth || !requested {
+		t.Errorf("result = %s (%v), writeable probe ran %t, want %s from the writeable probe", p.Result, err, requested, ProbeRequiresAuth)
+	}
+
+	requested = false
+	p, err = checkAll(newTestScanner(), repo)
+	if err != nil || p.Result != ProbeEmpty || requested {
+		t.Errorf("result = %s (%v), writeable probe ran %t, want %s without it", p.Result, err, requested, ProbeEmpty)
+	}
+}
+
+func TestWithGitStage(t *testing.T) {
+	names := func(stages []Stage) string {
+		var n []string
+		for _, stage := range stages {
+			n = append(n, stage.Name())
+		}
+		return strings.Join(n, ",")
+	}
+
+	if got, want := names(WithGitStage(AllStages())), "has-wiki,url,git,landing,min-size,firstpage,rules,new-link,writeable"; got != want {
+		t.Errorf("stages with git = %s, want %s", got, want)
+	}
+	if got, want := names(WithGitStage(AllStages()[:1])), "has-wiki,git"; got != want {
+		t.Errorf("stages with git = %s, want %s", got, want)
+	}
+}
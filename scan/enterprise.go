@@ -0,0 +1,109 @@
+package scan
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Points the API and wiki probes at the GitHub Enterprise Server at rawURL, such as https://github.example.com
+func (s *Scanner) SetGitHubURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case u.Scheme != "https" && u.Scheme != "http":
+		return fmt.Errorf("scheme must be https or http, got %q", u.Scheme)
+	case u.Host == "":
+		return fmt.Errorf("host is empty")
+	case u.User != nil || u.RawQuery != "" || u.Fragment != "":
+		return fmt.Errorf("must be just a scheme and host")
+	case strings.Trim(u.Path, "/") != "":
+		return fmt.Errorf("must not have a path, got %q", u.Path)
+	}
+
+	if strings.EqualFold(u.Host, "github.com") {
+		return nil
+	}
+
+	base := u.Scheme + "://" + u.Host
+	s.APIURL = base + "/api/v3"
+	s.GraphQLURL = base + "/api/graphql"
+	s.ProbeHost = base
+	s.enterpriseHost = &url.URL{Scheme: u.Scheme, Host: u.Host}
+
+	// StrictURLs should accept the server unless other hosts were asked for
+	if len(s.ProviderHosts) == 1 && s.ProviderHosts[0] == "github.com" {
+		s.ProviderHosts = []string{u.Host}
+	}
+
+	return nil
+}
+
+// Sets up TLS on the transport every client shares, trusting the CAs in caCertFile, such as an Enterprise
+// Server's private CA, as well as the system's so github.com is still checked as usual, or not checking
+// certificates at all when insecure is set
+func (s *Scanner) ConfigureTLS(caCertFile string, insecureSkipVerify bool) error {
+	if caCertFile == "" && !insecureSkipVerify {
+		return nil
+	}
+
+	config := &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+	if caCertFile != "" {
+		pem, err := os.ReadFile(caCertFile)
+		if err != nil {
+			return err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no PEM certificates in %s", caCertFile)
+		}
+		config.RootCAs = pool
+	}
+	s.transport.TLSClientConfig = config
+	s.caCertFile = caCertFile
+	s.insecureSkipVerify = insecureSkipVerify
+
+	return nil
+}
+
+// Gets the git options that match ConfigureTLS, for wiki clones
+//
+// Git's CA file replaces the system's rather than adding to it, so the CA file is only passed on for an
+// Enterprise Server, whose wikis are the ones cloned then.
+func (s *Scanner) gitTLSArgs() []string {
+	var args []string
+	if s.caCertFile != "" && s.enterpriseHost != nil {
+		args = append(args, "-c", "http.sslCAInfo="+s.caCertFile)
+	}
+	if s.insecureSkipVerify {
+		args = append(args, "-c", "http.sslVerify=false")
+	}
+
+	return args
+}
+
+// Moves a repository URL from the API onto the Enterprise Server's host, in case the server reports a different
+// one (such as an internal hostname) than the one we can reach
+func (s *Scanner) onProbeHost(repoURL string) string {
+	if s.enterpriseHost == nil {
+		return repoURL
+	}
+
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return repoURL
+	}
+	u.Scheme = s.enterpriseHost.Scheme
+	u.Host = s.enterpriseHost.Host
+
+	return u.String()
+}
@@ -0,0 +1,32 @@
+package scan
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// HTTPError is a response with a status we didn't expect
+type HTTPError struct {
+	what        string
+	StatusCode  int
+	Status      string
+	RateLimited bool
+}
+
+// Creates the error for a response to a request made to do what, noting whether it was rate limited
+func NewHTTPError(what string, resp *http.Response) *HTTPError {
+	limited := (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) &&
+		(resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.Header.Get("Retry-After") != "" ||
+			resp.StatusCode == http.StatusTooManyRequests)
+
+	return &HTTPError{
+		what:        what,
+		StatusCode:  resp.StatusCode,
+		Status:      resp.Status,
+		RateLimited: limited,
+	}
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("failed to %s: %s", e.what, e.Status)
+}
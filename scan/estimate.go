@@ -0,0 +1,193 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// RateLimit is the core API rate limit for the current token (or IP when unauthenticated)
+type RateLimit struct {
+	Limit     int   `json:"limit"`
+	Remaining int   `json:"remaining"`
+	Reset     int64 `json:"reset"`
+}
+
+// Estimate is the projected cost of scanning a set of accounts
+type Estimate struct {
+	Accounts     map[string]int
+	Repositories int
+	APICalls     int
+	// An upper bound on the wiki probes, as repositories are counted whether or not they have a wiki
+	MaxProbes int
+	Rate      RateLimit
+	// Whether the filters will leave some of the counted repositories out of the scan
+	Filtered bool
+}
+
+// Fits reports whether the listing calls fit in the remaining rate limit budget
+func (e Estimate) Fits() bool {
+	return e.APICalls <= e.Rate.Remaining
+}
+
+// Counts the repositories of an account target, from the listing the scan would make
+//
+// Like the scan, an account whose type isn't given that lists no repositories is counted the other way too, and
+// FixAccountType counts an org: or user: account that isn't found as its actual type. The count is capped at
+// MaxRepos, but can't take the filters on listed repositories into account without listing them all.
+func (s *Scanner) countRepositories(ctx context.Context, t target) (int, error) {
+	kind := t.kind
+	if kind == targetAccount {
+		kind = s.accountListingKind(ctx, t.name)
+	}
+
+	count, err := s.countAccountRepositories(ctx, kind, t.name)
+	var httpErr *HTTPError
+	switch {
+	case err == nil && count == 0 && t.kind == targetAccount:
+		if other, err := s.countAccountRepositories(ctx, otherAccountKind(kind), t.name); err == nil {
+			count = other
+		}
+	case s.FixAccountType && t.kind != targetAccount && errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound:
+		if actual, typeErr := s.getAccountType(ctx, t.name); typeErr == nil && actual != kind {
+			count, err = s.countAccountRepositories(ctx, actual, t.name)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if s.MaxRepos > 0 {
+		count = min(count, s.MaxRepos)
+	}
+	return count, nil
+}
+
+// Counts the repositories of an account listed as the given type from a single one-repo-per-page request
+func (s *Scanner) countAccountRepositories(ctx context.Context, kind, name string) (int, error) {
+	// With per_page=1 the last page number is the repository count
+	req, err := newAPIRequest(ctx, http.MethodGet, s.accountListingURL(ctx, kind, name, 1), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := s.getAPIClient().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, NewHTTPError("fetch repositories", resp)
+	}
+
+	if last := linkURL(resp.Header, "last"); last != "" {
+		return pageNumber(last)
+	}
+
+	// No pagination means everything is on this page
+	var repos []Repository
+	if err := json.NewDecoder(resp.Body).Decode(&repos); err != nil {
+		return 0, err
+	}
+
+	return len(repos), nil
+}
+
+// Gets the page query parameter from a pagination URL
+func pageNumber(pageURL string) (int, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return 0, err
+	}
+
+	page, err := strconv.Atoi(u.Query().Get("page"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid page in %q: %w", pageURL, err)
+	}
+
+	return page, nil
+}
+
+// Gets the core rate limit for the current token
+func (s *Scanner) getRateLimit(ctx context.Context) (RateLimit, error) {
+	req, err := newAPIRequest(ctx, http.MethodGet, s.APIURL+"/rate_limit", nil)
+	if err != nil {
+		return RateLimit{}, err
+	}
+
+	resp, err := s.getAPIClient().Do(req)
+	if err != nil {
+		return RateLimit{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return RateLimit{}, NewHTTPError("fetch rate limit", resp)
+	}
+
+	var body struct {
+		Resources struct {
+			Core RateLimit `json:"core"`
+		} `json:"resources"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return RateLimit{}, err
+	}
+
+	return body.Resources.Core, nil
+}
+
+// Computes the estimate for a set of accounts from their repository counts
+func computeEstimate(counts map[string]int, rate RateLimit) Estimate {
+	e := Estimate{Accounts: counts, Rate: rate}
+	for _, count := range counts {
+		e.Repositories += count
+
+		// Listing always takes at least one call, even for empty accounts
+		pages := (count + reposPerPage - 1) / reposPerPage
+		e.APICalls += max(pages, 1)
+	}
+
+	// Every repository with a wiki takes the landing page probe, plus the writeable probe at most. Which ones
+	// have a wiki isn't known without listing them, so this counts them all.
+	e.MaxProbes = e.Repositories * 2
+
+	return e
+}
+
+// Estimates the cost of scanning the given accounts, from their repository counts and the rate limit
+//
+// Only account targets can be estimated, anything else is an error.
+func (s *Scanner) Estimate(ctx context.Context, accounts []string) (Estimate, error) {
+	counts := make(map[string]int)
+	for _, orgName := range accounts {
+		if orgName == "" {
+			continue
+		}
+		t, err := parseAccountInput(orgName)
+		if err != nil || (t.kind != targetAccount && t.kind != targetOrg && t.kind != targetUser) {
+			return Estimate{}, fmt.Errorf("%s: only accounts can be estimated", orgName)
+		}
+
+		count, err := s.countRepositories(ctx, t)
+		if err != nil {
+			return Estimate{}, fmt.Errorf("%s: %w", orgName, err)
+		}
+		counts[orgName] = count
+	}
+
+	rate, err := s.getRateLimit(ctx)
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	e := computeEstimate(counts, rate)
+	e.Filtered = len(s.activeFilters()) > 0
+
+	return e, nil
+}
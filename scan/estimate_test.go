@@ -0,0 +1,159 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCountRepositories(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("per_page") != "1" {
+			t.Errorf("%s wasn't asked for one repository per page", r.URL)
+		}
+		switch r.URL.EscapedPath() {
+		case "/users/big/repos":
+			w.Header().Set("Link", fmt.Sprintf(`<%[1]s/users/big/repos?per_page=1&page=2>; rel="next", <%[1]s/users/big/repos?per_page=1&page=42>; rel="last"`, srv.URL))
+			fmt.Fprint(w, `[{"name": "first"}]`)
+		case "/users/one/repos":
+			fmt.Fprint(w, `[{"name": "only"}]`)
+		case "/users/none/repos":
+			fmt.Fprint(w, `[]`)
+		case "/users/odd%2Fname%20here/repos":
+			fmt.Fprint(w, `[{"name": "escaped"}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	s := NewScanner()
+	s.APIURL = srv.URL
+
+	tests := []struct {
+		account string
+		want    int
+	}{
+		{account: "big", want: 42},
+		{account: "one", want: 1},
+		{account: "none", want: 0},
+		{account: "odd/name here", want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.account, func(t *testing.T) {
+			got, err := s.countRepositories(context.Background(), target{kind: targetAccount, name: tt.account})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("countRepositories(%q) = %d, want %d", tt.account, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := s.countRepositories(context.Background(), target{kind: targetAccount, name: "missing"}); err == nil {
+		t.Error("counting a missing account succeeded")
+	}
+}
+
+func TestCountRepositoriesUsesTheScansListing(t *testing.T) {
+	var asked []string
+	mux := http.NewServeMux()
+	count := func(n int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			asked = append(asked, r.URL.Path+"?"+r.URL.RawQuery)
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?per_page=1&page=%d>; rel="last"`, r.Host, r.URL.Path, n))
+			fmt.Fprint(w, `[{"name": "first"}]`)
+		}
+	}
+	mux.HandleFunc("/orgs/acme/repos", count(250))
+	mux.HandleFunc("/users/acme", respond(http.StatusOK, `{"type": "Organization"}`))
+	mux.HandleFunc("/user", respond(http.StatusOK, `{"login": "me"}`))
+	mux.HandleFunc("/user/repos", count(7))
+	s, _ := fakeGitHub(t, mux)
+	s.AddTokens("secret")
+
+	tests := []struct {
+		name       string
+		target     target
+		visibility string
+		maxRepos   int
+		want       int
+		wantURL    string
+	}{
+		{name: "org", target: target{kind: targetOrg, name: "acme"}, visibility: VisibilityPublic, want: 250, wantURL: "/orgs/acme/repos?per_page=1"},
+		{name: "private account", target: target{kind: targetAccount, name: "acme"}, visibility: VisibilityAll, want: 250, wantURL: "/orgs/acme/repos?per_page=1"},
+		{name: "own private repositories", target: target{kind: targetUser, name: "me"}, visibility: VisibilityPrivate, want: 7, wantURL: "/user/repos?visibility=private&affiliation=owner&per_page=1"},
+		{name: "max repos", target: target{kind: targetOrg, name: "acme"}, visibility: VisibilityPublic, maxRepos: 100, want: 100, wantURL: "/orgs/acme/repos?per_page=1&sort=pushed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.Visibility, s.MaxRepos, asked = tt.visibility, tt.maxRepos, nil
+			got, err := s.countRepositories(context.Background(), tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("counted %d repositories, want %d", got, tt.want)
+			}
+			if len(asked) != 1 || asked[0] != tt.wantURL {
+				t.Errorf("counted from %q, want %s", asked, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestComputeEstimate(t *testing.T) {
+	counts := map[string]int{"empty": 0, "one": 1, "full": 100, "over": 101, "big": 250}
+	e := computeEstimate(counts, RateLimit{Limit: 5000, Remaining: 9})
+
+	if e.Repositories != 452 {
+		t.Errorf("repositories = %d, want 452", e.Repositories)
+	}
+	// Each account takes at least one listing call, then one per page of 100
+	if e.APICalls != 1+1+1+2+3 {
+		t.Errorf("API calls = %d, want 8", e.APICalls)
+	}
+	if e.MaxProbes != 904 {
+		t.Errorf("max probes = %d, want two for each repository", e.MaxProbes)
+	}
+	if !e.Fits() {
+		t.Errorf("8 calls don't fit in 9 remaining")
+	}
+	if e := computeEstimate(counts, RateLimit{Limit: 5000, Remaining: 7}); e.Fits() {
+		t.Errorf("8 calls fit in 7 remaining")
+	}
+}
+
+func TestEstimateFitsTheRateLimit(t *testing.T) {
+	for _, tt := range []struct {
+		remaining int
+		fits      bool
+	}{
+		{remaining: 3, fits: true},
+		{remaining: 2, fits: false},
+	} {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/orgs/acme/repos", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/orgs/acme/repos?per_page=1&page=201>; rel="last"`, r.Host))
+			fmt.Fprint(w, `[{"name": "first"}]`)
+		})
+		mux.HandleFunc("/rate_limit", respond(http.StatusOK, fmt.Sprintf(`{"resources": {"core": {"limit": 5000, "remaining": %d, "reset": 1767225600}}}`, tt.remaining)))
+		s, _ := fakeGitHub(t, mux)
+
+		e, err := s.Estimate(context.Background(), []string{"org:acme"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 201 repositories take three pages to list
+		if e.Repositories != 201 || e.APICalls != 3 || e.Rate.Remaining != tt.remaining || e.Fits() != tt.fits {
+			t.Errorf("with %d calls remaining the estimate is %+v, want 201 repositories in 3 calls that fit %t", tt.remaining, e, tt.fits)
+		}
+	}
+
+	if _, err := NewScanner().Estimate(context.Background(), []string{"repo:acme/docs"}); err == nil {
+		t.Error("a repository target was estimated")
+	}
+}
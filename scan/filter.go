@@ -0,0 +1,48 @@
+package scan
+
+import "time"
+
+// Filter says whether a listed repository should be probed
+type Filter func(Repository) bool
+
+// Whether a repository was pushed to before t, which ones the listing has no push time for aren't
+func pushedBefore(r Repository, t time.Time) bool {
+	return !r.PushedAt.IsZero() && r.PushedAt.Before(t)
+}
+
+// Gets the filters to apply, the ones for SkipArchived, SkipForks and PushedSince on top of Filters
+func (s *Scanner) activeFilters() []Filter {
+	filters := s.Filters
+	if s.SkipArchived {
+		filters = append(filters[:len(filters):len(filters)], func(r Repository) bool { return !r.Archived })
+	}
+	if s.SkipForks {
+		filters = append(filters[:len(filters):len(filters)], func(r Repository) bool { return !r.Fork })
+	}
+	if since := s.PushedSince; !since.IsZero() {
+		filters = append(filters[:len(filters):len(filters)], func(r Repository) bool { return !pushedBefore(r, since) })
+	}
+
+	return filters
+}
+
+// Gets the repositories that pass every filter, in the order they were given
+func (s *Scanner) filterRepositories(repos []Repository) []Repository {
+	filters := s.activeFilters()
+	if len(filters) == 0 {
+		return repos
+	}
+
+	var kept []Repository
+next:
+	for _, repo := range repos {
+		for _, keep := range filters {
+			if !keep(repo) {
+				continue next
+			}
+		}
+		kept = append(kept, repo)
+	}
+
+	return kept
+}
@@ -0,0 +1,79 @@
+package scan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Finding is the result of checking one repository's wiki
+type Finding struct {
+	Account string
+	Repo    string
+	// The repository, and the URL whose response decided the result
+	RepoURL string
+	URL     string
+	Result  ProbeResult
+	Private bool
+
+	Redirects []string
+	// Whether a writeable wiki could only be written to signed in
+	SignedInOnly bool
+	// The rule the landing page matched
+	Rule string
+
+	// The latest commit to the wiki, only looked up with WikiGitLog
+	LastAuthor string
+	LastEdited time.Time
+
+	CheckedAt time.Time
+}
+
+// Identifies a finding by what was checked and what came back, the same across runs
+func (f Finding) Fingerprint() string {
+	sum := sha256.Sum256([]byte(f.Account + "\x00" + f.Repo + "\x00" + f.URL + "\x00" + f.Result.String()))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Gets how a wiki is vulnerable, firstpage, writeable, writeable-authenticated or rule-match, or "" for wikis
+// that aren't
+func (f Finding) Vulnerability() string {
+	switch f.Result {
+	case ProbeRuleMatch:
+		return "rule-match"
+	case ProbeEmpty:
+		return "firstpage"
+	case ProbeWriteable:
+		if f.SignedInOnly {
+			return "writeable-authenticated"
+		}
+		return "writeable"
+	default:
+		return ""
+	}
+}
+
+// Every way Vulnerability says a wiki can be vulnerable, in the order metrics list them
+var vulnerabilityKinds = []string{"firstpage", "writeable", "writeable-authenticated", "rule-match"}
+
+// Severities of vulnerable wikis by how they're vulnerable: anyone can edit a wiki that's writeable, even if
+// they have to sign in first, while a firstpage one only lets them start it
+var severities = map[string]string{
+	"writeable":               "high",
+	"writeable-authenticated": "high",
+	"firstpage":               "medium",
+	"rule-match":              "medium",
+}
+
+// Gets how severe a vulnerable wiki is, high or medium, or "" for wikis that aren't vulnerable
+func (f Finding) Severity() string {
+	return severities[f.Vulnerability()]
+}
+
+// Reporter writes findings to an output
+type Reporter interface {
+	Report(f Finding) error
+	// Writes out anything that's been buffered
+	Flush() error
+	Close() error
+}
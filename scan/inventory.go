@@ -0,0 +1,41 @@
+package scan
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// InventoryEntry is one checked repository in the inventory
+type InventoryEntry struct {
+	Account string `json:"account"`
+	Repo    string `json:"repo"`
+	URL     string `json:"url"`
+	HasWiki bool   `json:"has_wiki"`
+	Result  string `json:"result"`
+}
+
+// Inventory records every checked repository as JSON lines, not just the vulnerable ones
+type Inventory struct {
+	enc *json.Encoder
+}
+
+func NewInventory(w io.Writer) *Inventory {
+	return &Inventory{enc: json.NewEncoder(w)}
+}
+
+// Records a checked repository, doing nothing on a nil inventory
+func (inv *Inventory) Record(account string, repo Repository, result ProbeResult) error {
+	if inv == nil {
+		return nil
+	}
+
+	entry := InventoryEntry{
+		Account: account,
+		Repo:    repo.Name,
+		URL:     repo.URL,
+		HasWiki: repo.HasWiki,
+		Result:  result.String(),
+	}
+
+	return inv.enc.Encode(entry)
+}
@@ -0,0 +1,57 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestInventoryRecordsEveryProbedRepository(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/acme/repos", listing(
+		Repository{Name: "open", URL: "/acme/open", HasWiki: true},
+		Repository{Name: "locked", URL: "/acme/locked", HasWiki: true},
+		Repository{Name: "code-only", URL: "/acme/code-only"},
+		Repository{Name: "broken", URL: "/acme/broken", HasWiki: true},
+	))
+	mux.HandleFunc("/acme/open/wiki", respond(http.StatusOK, populatedWikiBody))
+	mux.HandleFunc("/acme/open/wiki/", respond(http.StatusOK, populatedWikiBody))
+	mux.HandleFunc("/acme/locked/wiki", respond(http.StatusOK, populatedWikiBody))
+	mux.Handle("/acme/locked/wiki/", http.RedirectHandler("/login", http.StatusFound))
+	mux.HandleFunc("/acme/broken/wiki", respond(http.StatusInternalServerError, ""))
+	s, srv := fakeGitHub(t, mux)
+
+	var buf bytes.Buffer
+	run := s.NewRun()
+	run.Inventory = NewInventory(&buf)
+	if err := run.Scan(context.Background(), "acme", &findingCollector{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]InventoryEntry{
+		"open":      {Account: "acme", Repo: "open", URL: srv.URL + "/acme/open", HasWiki: true, Result: ProbeWriteable.String()},
+		"locked":    {Account: "acme", Repo: "locked", URL: srv.URL + "/acme/locked", HasWiki: true, Result: ProbeRequiresAuth.String()},
+		"code-only": {Account: "acme", Repo: "code-only", URL: srv.URL + "/acme/code-only", Result: ProbeNoWiki.String()},
+		"broken":    {Account: "acme", Repo: "broken", URL: srv.URL + "/acme/broken", HasWiki: true, Result: ProbeError.String()},
+	}
+	got := map[string]InventoryEntry{}
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var entry InventoryEntry
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		got[entry.Repo] = entry
+	}
+
+	if len(got) != len(want) {
+		t.Errorf("inventory has %d repositories, want %d: %v", len(got), len(want), got)
+	}
+	for name, entry := range want {
+		if got[name] != entry {
+			t.Errorf("inventory has %+v for %s, want %+v", got[name], name, entry)
+		}
+	}
+}
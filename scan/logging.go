@@ -0,0 +1,49 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// Gets the logger diagnostics go to
+func (s *Scanner) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+
+	return slog.Default()
+}
+
+func (s *Scanner) logf(level slog.Level, format string, args ...any) {
+	logger := s.logger()
+	if !logger.Enabled(context.Background(), level) {
+		return
+	}
+	logger.Log(context.Background(), level, fmt.Sprintf(format, args...))
+}
+
+func (s *Scanner) debugf(format string, args ...any) { s.logf(slog.LevelDebug, format, args...) }
+func (s *Scanner) infof(format string, args ...any)  { s.logf(slog.LevelInfo, format, args...) }
+func (s *Scanner) warnf(format string, args ...any)  { s.logf(slog.LevelWarn, format, args...) }
+
+// Hands an operational error that happened while doing op to OnError, or logs it when that isn't set
+func (s *Scanner) logError(level, op, account, repo string, err error) {
+	if s.OnError != nil {
+		s.OnError(level, op, account, repo, err)
+		return
+	}
+
+	var severity slog.Level
+	if severity.UnmarshalText([]byte(level)) != nil {
+		severity = slog.LevelError
+	}
+	switch {
+	case repo != "":
+		s.logf(severity, "%s %s/%s: %v", op, account, repo, err)
+	case account != "":
+		s.logf(severity, "%s %s: %v", op, account, err)
+	default:
+		s.logf(severity, "%s: %v", op, err)
+	}
+}
@@ -0,0 +1,107 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/offftherecord/gitwiki/internal/atomicfile"
+)
+
+// accountCoverage is what was covered of one account, as written to the manifest
+type accountCoverage struct {
+	Account string `json:"account"`
+	// org or user for accounts, or the kind of target for anything else
+	Type         string `json:"type"`
+	Repositories int    `json:"repositories"`
+	WithWiki     int    `json:"with_wiki"`
+	Probed       int    `json:"probed"`
+	Findings     int    `json:"findings"`
+	Vulnerable   int    `json:"vulnerable"`
+	// Whether the listing or the probes didn't get through every repository
+	Partial bool `json:"partial"`
+}
+
+// Manifest is an audit trail of every account a run covered, separate from the findings
+type Manifest struct {
+	ScanID     string             `json:"scan_id"`
+	StartedAt  string             `json:"started_at"`
+	FinishedAt string             `json:"finished_at"`
+	Accounts   []*accountCoverage `json:"accounts"`
+
+	// Whether account types are looked up, which takes an API call per account
+	detectTypes bool
+	byAccount   map[string]*accountCoverage
+}
+
+// Creates the manifest for the scan with the given ID, looking up the type of each account when detectTypes is set
+func NewManifest(scanID string, detectTypes bool) *Manifest {
+	return &Manifest{
+		ScanID:      scanID,
+		StartedAt:   time.Now().UTC().Format(time.RFC3339),
+		Accounts:    []*accountCoverage{},
+		detectTypes: detectTypes,
+		byAccount:   make(map[string]*accountCoverage),
+	}
+}
+
+// Gets the coverage of an account, adding it the first time it's seen
+func (m *Manifest) account(ctx context.Context, s *Scanner, account string, t target) *accountCoverage {
+	if c, ok := m.byAccount[account]; ok {
+		return c
+	}
+
+	c := &accountCoverage{Account: account, Type: t.kind}
+	switch t.kind {
+	case targetAccount, targetOrg, targetUser:
+		if !m.detectTypes {
+			break
+		}
+		if kind, err := s.getAccountType(ctx, t.name); err == nil {
+			c.Type = kind
+		} else {
+			s.logError("warn", "fetch account type", account, "", err)
+		}
+	}
+
+	m.byAccount[account] = c
+	m.Accounts = append(m.Accounts, c)
+
+	return c
+}
+
+// Counts a finding against its account
+func (m *Manifest) Record(account string, result ProbeResult) {
+	c, ok := m.byAccount[account]
+	if !ok {
+		return
+	}
+
+	c.Findings++
+	if result.Vulnerable() {
+		c.Vulnerable++
+	}
+}
+
+// Writes the manifest to path as JSON
+func (m *Manifest) Write(path string) error {
+	m.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := atomicfile.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.File.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	return f.Close()
+}
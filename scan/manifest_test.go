@@ -0,0 +1,70 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestManifestCountsPerAccount(t *testing.T) {
+	emptyWiki := strings.Repeat("<p>Nothing here</p>\n", 40) + WikiFirstPageMarker
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/acme", respond(http.StatusOK, `{"type": "User"}`))
+	mux.HandleFunc("/users/acme/repos", listing(
+		Repository{Name: "open", URL: "/acme/open", HasWiki: true},
+		Repository{Name: "locked", URL: "/acme/locked", HasWiki: true},
+		Repository{Name: "nowiki", URL: "/acme/nowiki"},
+	))
+	mux.HandleFunc("/acme/open/wiki", respond(http.StatusOK, populatedWikiBody))
+	mux.HandleFunc("/acme/open/wiki/", respond(http.StatusOK, populatedWikiBody))
+	mux.HandleFunc("/acme/locked/wiki", respond(http.StatusOK, populatedWikiBody))
+	mux.Handle("/acme/locked/wiki/", http.RedirectHandler("/login", http.StatusFound))
+	mux.HandleFunc("/users/globex", respond(http.StatusOK, `{"type": "Organization"}`))
+	mux.HandleFunc("/orgs/globex/repos", listing(
+		Repository{Name: "empty", URL: "/globex/empty", HasWiki: true},
+		Repository{Name: "nowiki", URL: "/globex/nowiki"},
+	))
+	mux.HandleFunc("/globex/empty/wiki", respond(http.StatusOK, emptyWiki))
+	s, _ := fakeGitHub(t, mux)
+
+	run := s.NewRun()
+	run.Manifest = NewManifest(s.ScanID, true)
+	for _, account := range []string{"acme", "org:globex"} {
+		if err := run.Scan(context.Background(), account, &findingCollector{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := run.Manifest.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []accountCoverage{
+		{Account: "acme", Type: targetUser, Repositories: 3, WithWiki: 2, Probed: 2, Findings: 3, Vulnerable: 1},
+		{Account: "org:globex", Type: targetOrg, Repositories: 2, WithWiki: 1, Probed: 1, Findings: 2, Vulnerable: 1},
+	}
+	if len(m.Accounts) != len(want) {
+		t.Fatalf("manifest has %d accounts, want %d:\n%s", len(m.Accounts), len(want), data)
+	}
+	for i, c := range m.Accounts {
+		if *c != want[i] {
+			t.Errorf("account %d = %+v, want %+v", i, *c, want[i])
+		}
+	}
+	if m.ScanID != s.ScanID || m.StartedAt == "" || m.FinishedAt == "" {
+		t.Errorf("manifest header = %q %q %q, want the scan ID and both times", m.ScanID, m.StartedAt, m.FinishedAt)
+	}
+}
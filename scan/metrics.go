@@ -0,0 +1,138 @@
+package scan
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Upper bounds of the probe latency histogram's buckets, in seconds
+var probeLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Metrics counts what a scan has done, for Prometheus to scrape
+//
+// Counting is a no-op on nil, so a Scanner without Metrics doesn't count anything.
+type Metrics struct {
+	mu             sync.Mutex
+	accounts       int
+	reposListed    int
+	reposScanned   int
+	results        map[ProbeResult]int
+	findings       map[string]int
+	httpErrors     map[string]int
+	latencyCounts  []int
+	latencySum     float64
+	latencySamples int
+}
+
+// Creates metrics with nothing counted yet
+func NewMetrics() *Metrics {
+	return &Metrics{
+		results:       make(map[ProbeResult]int),
+		findings:      make(map[string]int),
+		httpErrors:    make(map[string]int),
+		latencyCounts: make([]int, len(probeLatencyBuckets)),
+	}
+}
+
+// Counts an account whose repositories were listed
+func (m *Metrics) listed(n int) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.accounts++
+	m.reposListed += n
+}
+
+// Counts a classified repository, and its finding when it's vulnerable
+func (m *Metrics) scanned(f Finding) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.reposScanned++
+	m.results[f.Result]++
+	if v := f.Vulnerability(); v != "" {
+		m.findings[v]++
+	}
+}
+
+// Counts a request to the wiki host or the API that failed or got a server error, by which of them it went to
+func (m *Metrics) httpError(source string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.httpErrors[source]++
+}
+
+// Adds how long a probe took to the latency histogram
+func (m *Metrics) probeLatency(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seconds := d.Seconds()
+	for i, bound := range probeLatencyBuckets {
+		if seconds <= bound {
+			m.latencyCounts[i]++
+		}
+	}
+	m.latencySum += seconds
+	m.latencySamples++
+}
+
+// Writes the metrics in the Prometheus text format
+func (m *Metrics) Write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP gitwiki_accounts_scanned_total Accounts and other targets whose repositories were listed.")
+	fmt.Fprintln(w, "# TYPE gitwiki_accounts_scanned_total counter")
+	fmt.Fprintf(w, "gitwiki_accounts_scanned_total %d\n", m.accounts)
+
+	fmt.Fprintln(w, "# HELP gitwiki_repos_listed_total Repositories listed across every target.")
+	fmt.Fprintln(w, "# TYPE gitwiki_repos_listed_total counter")
+	fmt.Fprintf(w, "gitwiki_repos_listed_total %d\n", m.reposListed)
+
+	fmt.Fprintln(w, "# HELP gitwiki_repos_scanned_total Repositories classified.")
+	fmt.Fprintln(w, "# TYPE gitwiki_repos_scanned_total counter")
+	fmt.Fprintf(w, "gitwiki_repos_scanned_total %d\n", m.reposScanned)
+
+	fmt.Fprintln(w, "# HELP gitwiki_results_total Repositories classified, by result.")
+	fmt.Fprintln(w, "# TYPE gitwiki_results_total counter")
+	for r := ProbeNoWiki; r <= ProbeRuleMatch; r++ {
+		fmt.Fprintf(w, "gitwiki_results_total{result=%q} %d\n", r, m.results[r])
+	}
+
+	fmt.Fprintln(w, "# HELP gitwiki_findings_total Vulnerable wikis found, by how they're vulnerable.")
+	fmt.Fprintln(w, "# TYPE gitwiki_findings_total counter")
+	for _, v := range vulnerabilityKinds {
+		fmt.Fprintf(w, "gitwiki_findings_total{type=%q} %d\n", v, m.findings[v])
+	}
+
+	fmt.Fprintln(w, "# HELP gitwiki_http_errors_total Requests that failed or got a server error, by where they went.")
+	fmt.Fprintln(w, "# TYPE gitwiki_http_errors_total counter")
+	for _, source := range []string{"api", "probe"} {
+		fmt.Fprintf(w, "gitwiki_http_errors_total{source=%q} %d\n", source, m.httpErrors[source])
+	}
+
+	fmt.Fprintln(w, "# HELP gitwiki_probe_duration_seconds How long wiki probes took.")
+	fmt.Fprintln(w, "# TYPE gitwiki_probe_duration_seconds histogram")
+	for i, bound := range probeLatencyBuckets {
+		fmt.Fprintf(w, "gitwiki_probe_duration_seconds_bucket{le=\"%g\"} %d\n", bound, m.latencyCounts[i])
+	}
+	fmt.Fprintf(w, "gitwiki_probe_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.latencySamples)
+	fmt.Fprintf(w, "gitwiki_probe_duration_seconds_sum %g\n", m.latencySum)
+	fmt.Fprintf(w, "gitwiki_probe_duration_seconds_count %d\n", m.latencySamples)
+}
@@ -0,0 +1,31 @@
+package scan
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFindingsMetricCoversEveryKind(t *testing.T) {
+	findings := []Finding{
+		{Result: ProbeEmpty},
+		{Result: ProbeWriteable},
+		{Result: ProbeWriteable, SignedInOnly: true},
+		{Result: ProbeRuleMatch, Rule: "internal-docs"},
+	}
+
+	m := NewMetrics()
+	for _, f := range findings {
+		m.scanned(f)
+	}
+	var buf bytes.Buffer
+	m.Write(&buf)
+
+	for _, f := range findings {
+		series := fmt.Sprintf("gitwiki_findings_total{type=%q} 1\n", f.Vulnerability())
+		if !strings.Contains(buf.String(), series) {
+			t.Errorf("metrics are missing %q:\n%s", strings.TrimSpace(series), buf.String())
+		}
+	}
+}
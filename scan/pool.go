@@ -0,0 +1,118 @@
+package scan
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Probes run at once by default
+const defaultConcurrency = 10
+
+// probeJob is a repository being probed by the worker pool
+type probeJob struct {
+	repo Repository
+	// Gets the outcome once the probe is done
+	done chan probeOutcome
+}
+
+// probeOutcome is what checkWiki came back with
+type probeOutcome struct {
+	p   Probe
+	err error
+	// The probe was skipped because the account's wikis look disabled
+	skipped bool
+}
+
+// probePool probes repositories with a fixed number of workers, handing back the outcomes in the order the
+// repositories were given so the scan's output doesn't depend on which probe finished first
+type probePool struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	ordered chan *probeJob
+	wg      sync.WaitGroup
+}
+
+// Starts probing repos with the run's workers, skipping wikis once skip is set
+//
+// Each probe holds one of the run's probe slots while it runs, which are shared by the pools of every account
+// scanned at once so that they don't make more than Concurrency probes between them.
+func (run *Run) startProbePool(ctx context.Context, orgName string, repos []Repository, skip *atomic.Bool) *probePool {
+	ctx, cancel := context.WithCancel(ctx)
+	pool := &probePool{ctx: ctx, cancel: cancel, ordered: make(chan *probeJob, run.concurrency)}
+	slots := run.probeSlots
+	tracer := run.s.tracer()
+
+	jobs := make(chan *probeJob)
+	for i := 0; i < run.concurrency; i++ {
+		pool.wg.Add(1)
+		go func() {
+			defer pool.wg.Done()
+			for job := range jobs {
+				if job.repo.HasWiki && skip.Load() {
+					job.done <- probeOutcome{skipped: true}
+					continue
+				}
+
+				// The pause is taken before the slot, so it doesn't hold up other accounts' probes
+				if run.s.politeDelay(ctx) != nil {
+					return
+				}
+				select {
+				case slots <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+				probeCtx, endProbe := tracer.StartProbe(ctx, orgName, job.repo)
+				p, err := run.checkWiki(probeCtx, job.repo)
+				endProbe(p, err)
+				<-slots
+				job.done <- probeOutcome{p: p, err: err}
+			}
+		}()
+	}
+
+	pool.wg.Add(1)
+	go func() {
+		defer pool.wg.Done()
+		defer close(jobs)
+		defer close(pool.ordered)
+
+		for _, repo := range repos {
+			job := &probeJob{repo: repo, done: make(chan probeOutcome, 1)}
+			select {
+			case pool.ordered <- job:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return pool
+}
+
+// Gets the outcome of the next repository, in order, or false once there are no more or the context is done
+func (pool *probePool) next() (probeOutcome, bool) {
+	job, ok := <-pool.ordered
+	if !ok {
+		return probeOutcome{}, false
+	}
+
+	select {
+	case o := <-job.done:
+		return o, true
+	case <-pool.ctx.Done():
+		return probeOutcome{}, false
+	}
+}
+
+// Stops the pool, cutting off probes still running, and waits for its workers to finish
+func (pool *probePool) stop() {
+	pool.cancel()
+	pool.wg.Wait()
+}
@@ -0,0 +1,144 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Gets a run whose probes are check, rather than requests to the repositories' wikis
+func stubbedRun(concurrency int, check func(repo Repository) Probe) *Run {
+	s := NewScanner()
+	s.Concurrency = concurrency
+	s.Stages = []Stage{{name: "stub", run: func(ctx context.Context, c *wikiCheck) (bool, error) {
+		c.probe = check(c.repo)
+		return true, nil
+	}}}
+
+	return s.NewRun()
+}
+
+func TestProbePoolRunsConcurrentlyInOrder(t *testing.T) {
+	var repos []Repository
+	delays := map[string]time.Duration{}
+	for i := 0; i < 10; i++ {
+		repo := Repository{Name: fmt.Sprintf("repo%d", i), HasWiki: true}
+		repos = append(repos, repo)
+		// Later repositories finish first, which mustn't change the order they come back in
+		delays[repo.Name] = time.Duration(100-8*i) * time.Millisecond
+	}
+
+	var mu sync.Mutex
+	running, most := 0, 0
+	var skip atomic.Bool
+	start := time.Now()
+	run := stubbedRun(5, func(repo Repository) Probe {
+		mu.Lock()
+		running++
+		most = max(most, running)
+		mu.Unlock()
+
+		time.Sleep(delays[repo.Name])
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return Probe{URL: repo.Name}
+	})
+	pool := run.startProbePool(context.Background(), "acme", repos, &skip)
+	defer pool.stop()
+
+	for i, repo := range repos {
+		o, ok := pool.next()
+		if !ok {
+			t.Fatalf("pool ended after %d of %d repositories", i, len(repos))
+		}
+		if o.p.URL != repo.Name {
+			t.Errorf("outcome %d is for %s, want %s", i, o.p.URL, repo.Name)
+		}
+	}
+	if _, ok := pool.next(); ok {
+		t.Error("pool handed back more outcomes than repositories")
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("10 probes of up to 100ms took %s with 5 workers", elapsed)
+	}
+	if most != 5 {
+		t.Errorf("at most %d probes ran at once, want 5", most)
+	}
+}
+
+func TestSlowWikisDontSerializeTheScan(t *testing.T) {
+	const repos, delay = 8, 200 * time.Millisecond
+	var listed []Repository
+	mux := http.NewServeMux()
+	for i := 0; i < repos; i++ {
+		name := fmt.Sprintf("repo%d", i)
+		listed = append(listed, Repository{Name: name, URL: "/acme/" + name, HasWiki: true})
+		mux.HandleFunc("/acme/"+name+"/wiki", func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(delay)
+			w.Write([]byte(populatedWikiBody))
+		})
+		mux.Handle("/acme/"+name+"/wiki/", http.RedirectHandler("/login", http.StatusFound))
+	}
+	mux.HandleFunc("/users/acme/repos", listing(listed...))
+	s, _ := fakeGitHub(t, mux)
+	s.Concurrency = repos
+	start := time.Now()
+	findings, err := s.ScanAccount(context.Background(), "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > repos*delay/2 {
+		t.Errorf("%d wikis taking %s each were scanned in %s, as if one at a time", repos, delay, elapsed)
+	}
+
+	if len(findings) != repos {
+		t.Fatalf("got %d findings, want %d", len(findings), repos)
+	}
+	for i, f := range findings {
+		if want := listed[i].Name; f.Repo != want {
+			t.Errorf("finding %d is for %s, want %s in listing order", i, f.Repo, want)
+		}
+	}
+}
+
+func TestDelayIsSharedByEveryWorker(t *testing.T) {
+	const delay = 50 * time.Millisecond
+
+	var repos []Repository
+	for i := 0; i < 6; i++ {
+		repos = append(repos, Repository{Name: fmt.Sprintf("repo%d", i), HasWiki: true})
+	}
+	var mu sync.Mutex
+	var started []time.Time
+	var skip atomic.Bool
+	run := stubbedRun(10, func(repo Repository) Probe {
+		mu.Lock()
+		started = append(started, time.Now())
+		mu.Unlock()
+		return Probe{URL: repo.Name}
+	})
+	run.s.Delay = delay
+	pool := run.startProbePool(context.Background(), "acme", repos, &skip)
+	defer pool.stop()
+	for range repos {
+		if _, ok := pool.next(); !ok {
+			t.Fatal("pool ended early")
+		}
+	}
+
+	// With ten workers each pausing on its own, all six probes would start at about the same time
+	sort.Slice(started, func(i, j int) bool { return started[i].Before(started[j]) })
+	for i := 1; i < len(started); i++ {
+		if gap := started[i].Sub(started[i-1]); gap < delay-5*time.Millisecond {
+			t.Errorf("probe %d started %s after the one before, want at least Delay %s", i, gap, delay)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// cancelOnClose ends a probe's own deadline once its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+
+	return err
+}
+
+// Sends a probe, holding off while probes are throttled
+//
+// The probe gets its own deadline under the scan's, so the request's context is left as it is for retries.
+func (s *Scanner) doProbe(client *http.Client, req *http.Request) (*http.Response, error) {
+	if err := s.throttle.wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	cancel := context.CancelFunc(func() {})
+	if s.RequestTimeout > 0 {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(req.Context(), s.RequestTimeout)
+		req = req.WithContext(ctx)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	s.Metrics.probeLatency(time.Since(start))
+	if err != nil {
+		cancel()
+		s.Metrics.httpError("probe")
+		s.debugf("Probe failed: %v", err)
+		return nil, err
+	}
+	if resp.StatusCode >= 500 {
+		s.Metrics.httpError("probe")
+	}
+	resp.Body = cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	if location := resp.Header.Get("Location"); location != "" {
+		s.debugf("Probe %s %s: %s, redirecting to %s", req.Method, req.URL, resp.Status, location)
+	} else {
+		s.debugf("Probe %s %s: %s", req.Method, req.URL, resp.Status)
+	}
+	if wait, extended := s.throttle.observe(resp, s.BackoffJitter); extended {
+		s.warnf("Wiki probes were throttled, backing off for %s", wait.Round(time.Second))
+	}
+
+	return resp, nil
+}
+
+// Gets a wiki page
+func (s *Scanner) probe(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := s.newProbeRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.doProbeWithRetries(client, req)
+}
+
+// Size of the chunks a landing page is read in, and searched for the empty wiki markers as it comes in
+const landingChunkSize = 32 << 10
+
+// Reads a landing page up to MaxBodyBytes, stopping as soon as it has an empty wiki marker and is big enough to
+// classify when nothing later in the page can change the result
+//
+// Only FirstPageOnly, which looks for the new page link, NoFirstPage and Rules need the rest of the page.
+func (s *Scanner) readLanding(r io.Reader) (string, error) {
+	if s.MaxBodyBytes > 0 {
+		r = io.LimitReader(r, int64(s.MaxBodyBytes))
+	}
+	stopEarly := !s.NoFirstPage && !s.FirstPageOnly && len(s.Rules) == 0
+
+	longest := 0
+	for _, marker := range s.FirstPageMarkers {
+		longest = max(longest, len(marker))
+	}
+
+	var body bytes.Buffer
+	chunk := make([]byte, landingChunkSize)
+	// Where the search picks up, far enough back to catch a marker split between chunks
+	searched := 0
+	for {
+		n, err := r.Read(chunk)
+		body.Write(chunk[:n])
+		if stopEarly && body.Len() >= s.MinBodySize && body.Len() > searched {
+			from := max(0, searched-longest+1)
+			if s.hasFirstPageMarker(string(body.Bytes()[from:])) {
+				return body.String(), nil
+			}
+			searched = body.Len()
+		}
+
+		if err == io.EOF {
+			return body.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// Gets a wiki's landing page, only the start of it with RangeBytes
+//
+// The marker and the new page link are near the top of the page. Servers that ignore the Range header send
+// the whole page with a 200, which works just as well.
+func (s *Scanner) probeLanding(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := s.newProbeRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if s.RangeBytes > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", s.RangeBytes-1))
+	}
+
+	return s.doProbeWithRetries(client, req)
+}
+
+// ProbeResult is how a repository's wiki was classified
+type ProbeResult int
+
+const (
+	// The repository doesn't have a wiki, so it wasn't probed
+	ProbeNoWiki ProbeResult = iota
+	// GitHub redirected us to log in
+	ProbeRequiresAuth
+	// The wiki has no pages yet and offers to create the first one
+	ProbeEmpty
+	// A page that doesn't exist can be opened for editing
+	ProbeWriteable
+	// The wiki redirected somewhere other than login, usually because it's turned off
+	ProbeDisabled
+	// The probe failed or the server errored
+	ProbeError
+	// The wiki or the probed page came back as not found
+	ProbeSoftNotFound
+	// The wiki has pages and gave no sign that it can be edited
+	ProbeReadOnly
+	// The landing page was too small to be a real wiki page, so it was skipped
+	ProbeUnexpected
+	// The account turned wikis off for all its repositories, so this one wasn't probed
+	ProbeOrgDisabled
+	// The wiki host rate limited us, so we don't know anything yet
+	ProbeThrottled
+	// The repository URL failed StrictURLs validation, so it wasn't probed
+	ProbeInvalidURL
+	// The wiki has pages anyone can read but not edit, only reported with ReportReadable
+	ProbeReadable
+	// The repository says it has a wiki, but the wiki redirected to the repository, as it does when an organization
+	// or enterprise has turned wikis off
+	ProbeAdminDisabled
+	// The landing page matched one of the Rules
+	ProbeRuleMatch
+)
+
+var probeResultNames = map[ProbeResult]string{
+	ProbeNoWiki:        "no-wiki",
+	ProbeRequiresAuth:  "requires-auth",
+	ProbeEmpty:         "empty",
+	ProbeWriteable:     "writeable",
+	ProbeDisabled:      "disabled",
+	ProbeError:         "error",
+	ProbeSoftNotFound:  "soft-not-found",
+	ProbeReadOnly:      "read-only",
+	ProbeUnexpected:    "unexpected",
+	ProbeOrgDisabled:   "org-disabled",
+	ProbeThrottled:     "throttled",
+	ProbeInvalidURL:    "invalid-url",
+	ProbeReadable:      "readable",
+	ProbeAdminDisabled: "admin-disabled",
+	ProbeRuleMatch:     "rule-match",
+}
+
+func (r ProbeResult) String() string {
+	return probeResultNames[r]
+}
+
+// Whether the result means anyone can edit the wiki, or it matched a rule saying as much
+func (r ProbeResult) Vulnerable() bool {
+	return r == ProbeEmpty || r == ProbeWriteable || r == ProbeRuleMatch
+}
+
+// Whether the result means the wiki is enabled but locked down, so it can't be edited by just anyone
+func (r ProbeResult) Safe() bool {
+	switch r {
+	case ProbeRequiresAuth, ProbeSoftNotFound, ProbeReadOnly, ProbeReadable:
+		return true
+	default:
+		return false
+	}
+}
+
+// Checks whether a redirect goes back to the repository itself, which is where a wiki that's turned off sends us
+func isRepoRedirect(resp *http.Response, repoURL string) bool {
+	location, err := resp.Location()
+	if err != nil {
+		return false
+	}
+	repo, err := url.Parse(repoURL)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(strings.TrimSuffix(location.Path, "/"), strings.TrimSuffix(repo.Path, "/"))
+}
+
+// Checks whether a response is GitHub sending us off to log in
+func isLoginRedirect(resp *http.Response) bool {
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return false
+	}
+	location, err := resp.Location()
+	if err != nil {
+		return false
+	}
+
+	// SAML SSO protected organizations send us to their SSO page rather than the login page
+	return strings.HasPrefix(location.Path, "/login") ||
+		(strings.HasPrefix(location.Path, "/orgs/") && strings.HasSuffix(location.Path, "/sso"))
+}
+
+// Classifies a probe response that isn't a 200, with an error when it's unexpected
+func classifyStatus(resp *http.Response) (ProbeResult, error) {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return ProbeThrottled, NewHTTPError("probe wiki", resp)
+	case isLoginRedirect(resp):
+		return ProbeRequiresAuth, nil
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		return ProbeDisabled, nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ProbeSoftNotFound, nil
+	default:
+		return ProbeError, NewHTTPError("probe wiki", resp)
+	}
+}
+
+// Gets every probe result, in order
+func ProbeResults() []ProbeResult {
+	results := make([]ProbeResult, 0, len(probeResultNames))
+	for r := ProbeResult(0); int(r) < len(probeResultNames); r++ {
+		results = append(results, r)
+	}
+
+	return results
+}
+
+// Gets the result a name from ProbeResult.String stands for
+func ParseProbeResult(name string) (ProbeResult, bool) {
+	for result, resultName := range probeResultNames {
+		if resultName == name {
+			return result, true
+		}
+	}
+
+	return 0, false
+}
+
+// Text on the landing page of an empty wiki that anyone can create the first page of
+const WikiFirstPageMarker = "Create the first page"
+
+// Whether a landing page has any of the empty wiki markers
+func (s *Scanner) hasFirstPageMarker(body string) bool {
+	for _, marker := range s.FirstPageMarkers {
+		if strings.Contains(body, marker) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Probe is the outcome of checking a repository's wiki
+type Probe struct {
+	Result ProbeResult
+	// The wiki URL the result is reported with, and the status code of the response that decided it
+	URL    string
+	Status int
+	// Redirect hops from URL, only recorded with TraceRedirects
+	Redirects []string
+	// Whether a writeable wiki could only be written to signed in, as the probe without the token was turned away
+	SignedInOnly bool
+	// The rule the landing page matched
+	Rule string
+}
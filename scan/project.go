@@ -0,0 +1,143 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Lists the repositories of every issue and pull request on an organization's project
+const projectItemsQuery = `query($org: String!, $number: Int!, $cursor: String) {
+  organization(login: $org) {
+    projectV2(number: $number) {
+      items(first: 100, after: $cursor) {
+        pageInfo { hasNextPage endCursor }
+        nodes {
+          content {
+            ... on Issue { repository { ...repo } }
+            ... on PullRequest { repository { ...repo } }
+          }
+        }
+      }
+    }
+  }
+}
+
+fragment repo on Repository { name url hasWikiEnabled isPrivate visibility isArchived isFork pushedAt }`
+
+// projectRepository is a repository as returned by the GraphQL API
+type projectRepository struct {
+	Name           string    `json:"name"`
+	URL            string    `json:"url"`
+	HasWikiEnabled bool      `json:"hasWikiEnabled"`
+	IsPrivate      bool      `json:"isPrivate"`
+	Visibility     string    `json:"visibility"`
+	IsArchived     bool      `json:"isArchived"`
+	IsFork         bool      `json:"isFork"`
+	PushedAt       time.Time `json:"pushedAt"`
+}
+
+type projectItemsResponse struct {
+	Data struct {
+		Organization struct {
+			ProjectV2 *struct {
+				Items struct {
+					PageInfo struct {
+						HasNextPage bool   `json:"hasNextPage"`
+						EndCursor   string `json:"endCursor"`
+					} `json:"pageInfo"`
+					Nodes []struct {
+						Content struct {
+							Repository *projectRepository `json:"repository"`
+						} `json:"content"`
+					} `json:"nodes"`
+				} `json:"items"`
+			} `json:"projectV2"`
+		} `json:"organization"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// Gets the public repositories linked from an organization project's issues and pull requests
+//
+// The GraphQL API always needs a token, so this needs GITHUB_TOKEN.
+func (s *Scanner) getProjectRepositories(ctx context.Context, orgName string, number int) ([]Repository, error) {
+	client := s.getAPIClient()
+
+	var repos []Repository
+	seen := make(map[string]bool)
+	var cursor *string
+	for {
+		body, err := json.Marshal(map[string]any{
+			"query":     projectItemsQuery,
+			"variables": map[string]any{"org": orgName, "number": number, "cursor": cursor},
+		})
+		if err != nil {
+			return listingFailed(repos, err)
+		}
+
+		req, err := newAPIRequest(ctx, http.MethodPost, s.GraphQLURL, bytes.NewReader(body))
+		if err != nil {
+			return listingFailed(repos, err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return listingFailed(repos, err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return listingFailed(repos, NewHTTPError("fetch project items", resp))
+		}
+
+		var page projectItemsResponse
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return listingFailed(repos, err)
+		}
+		if len(page.Errors) > 0 {
+			return listingFailed(repos, errors.New(page.Errors[0].Message))
+		}
+
+		project := page.Data.Organization.ProjectV2
+		if project == nil {
+			return listingFailed(repos, errors.New("project not found"))
+		}
+
+		for _, node := range project.Items.Nodes {
+			// Draft issues don't belong to a repository
+			repo := node.Content.Repository
+			if repo == nil || seen[repo.URL] {
+				continue
+			}
+			seen[repo.URL] = true
+
+			r := Repository{
+				Name:       repo.Name,
+				URL:        repo.URL,
+				HasWiki:    repo.HasWikiEnabled,
+				Private:    repo.IsPrivate,
+				Visibility: strings.ToLower(repo.Visibility),
+				Archived:   repo.IsArchived,
+				Fork:       repo.IsFork,
+				PushedAt:   repo.PushedAt,
+			}
+			if s.scannable(r) {
+				repos = append(repos, r)
+			}
+		}
+
+		if !project.Items.PageInfo.HasNextPage {
+			return repos, nil
+		}
+		cursor = &project.Items.PageInfo.EndCursor
+	}
+}
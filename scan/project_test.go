@@ -0,0 +1,71 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// A project item linked to a repository, for a mocked GraphQL response
+func projectItem(name string, private bool) string {
+	visibility := "PUBLIC"
+	if private {
+		visibility = "PRIVATE"
+	}
+
+	return fmt.Sprintf(`{"content": {"repository": {"name": %q, "url": "https://github.com/acme/%s", "hasWikiEnabled": true, "isPrivate": %t, "visibility": %q}}}`,
+		name, name, private, visibility)
+}
+
+func TestProjectRepositories(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables struct {
+				Org    string  `json:"org"`
+				Number int     `json:"number"`
+				Cursor *string `json:"cursor"`
+			} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+			return
+		}
+		if req.Variables.Org != "acme" {
+			fmt.Fprint(w, `{"data": {"organization": {"projectV2": null}}}`)
+			return
+		}
+		if req.Variables.Number != 7 {
+			t.Errorf("asked for project %d, want 7", req.Variables.Number)
+		}
+
+		// A draft issue, a repository linked twice and a private one on the first page, then one more
+		if req.Variables.Cursor == nil {
+			fmt.Fprintf(w, `{"data": {"organization": {"projectV2": {"items": {"pageInfo": {"hasNextPage": true, "endCursor": "c1"}, "nodes": [%s, {"content": {}}, %s, %s]}}}}}`,
+				projectItem("docs", false), projectItem("docs", false), projectItem("secret", true))
+			return
+		}
+		if *req.Variables.Cursor != "c1" {
+			t.Errorf("asked for the page after %q, want c1", *req.Variables.Cursor)
+		}
+		fmt.Fprintf(w, `{"data": {"organization": {"projectV2": {"items": {"pageInfo": {"hasNextPage": false}, "nodes": [%s]}}}}}`,
+			projectItem("handbook", false))
+	}))
+	defer srv.Close()
+	s := NewScanner()
+	s.GraphQLURL = srv.URL + "/graphql"
+
+	repos, err := s.getProjectRepositories(context.Background(), "acme", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 || repos[0].Name != "docs" || repos[1].Name != "handbook" || !repos[0].HasWiki {
+		t.Errorf("listed %+v, want the public docs and handbook repositories", repos)
+	}
+
+	if _, err := s.getProjectRepositories(context.Background(), "globex", 7); err == nil {
+		t.Error("listing a project that isn't there succeeded")
+	}
+}
@@ -0,0 +1,41 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Follows up to max redirects from a redirect response, recording each hop as "status url"
+//
+// This never changes the result, detection always goes by the first response. Every hop goes out on the scan's
+// context, as closing a probe's body ends the context its request had.
+func (s *Scanner) traceRedirectChain(ctx context.Context, client *http.Client, resp *http.Response, max int) []string {
+	chain := []string{fmt.Sprintf("%d %s", resp.StatusCode, resp.Request.URL)}
+
+	for hops := 0; hops < max && resp.StatusCode >= 300 && resp.StatusCode < 400; hops++ {
+		location, err := resp.Location()
+		if err != nil {
+			break
+		}
+
+		next, err := s.probe(ctx, client, location.String())
+		if err != nil {
+			chain = append(chain, fmt.Sprintf("error %s: %v", location, err))
+			break
+		}
+		drainAndClose(next)
+
+		chain = append(chain, fmt.Sprintf("%d %s", next.StatusCode, location))
+		resp = next
+	}
+
+	return chain
+}
+
+// Records the redirect chain on a probe when tracing is on and the response was a redirect
+func (s *Scanner) recordRedirects(ctx context.Context, client *http.Client, p *Probe, resp *http.Response) {
+	if s.TraceRedirects > 0 && resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		p.Redirects = s.traceRedirectChain(ctx, client, resp, s.TraceRedirects)
+	}
+}
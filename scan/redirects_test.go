@@ -0,0 +1,77 @@
+package scan
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTraceRedirectChain(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/o/r/wiki", http.RedirectHandler("/a", http.StatusFound))
+	mux.Handle("/a", http.RedirectHandler("/b", http.StatusFound))
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx := context.Background()
+	s := NewScanner()
+	client := s.NewClient()
+	resp, err := s.probe(ctx, client, srv.URL+"/o/r/wiki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainAndClose(resp)
+
+	got := s.traceRedirectChain(ctx, client, resp, 3)
+	want := []string{
+		"302 " + srv.URL + "/o/r/wiki",
+		"302 " + srv.URL + "/a",
+		"200 " + srv.URL + "/b",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("chain = %q, want %q", got, want)
+	}
+}
+
+func TestTraceRedirectsOnProbes(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/o/r/wiki", http.RedirectHandler("/hop1", http.StatusFound))
+	mux.Handle("/hop1", http.RedirectHandler("/hop2", http.StatusMovedPermanently))
+	mux.Handle("/hop2", http.RedirectHandler("/elsewhere", http.StatusFound))
+	mux.HandleFunc("/elsewhere", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("elsewhere"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	repo := Repository{Name: "o/r", URL: srv.URL + "/o/r", HasWiki: true}
+
+	tests := []struct {
+		trace int
+		want  []string
+	}{
+		{trace: 0},
+		{trace: 2, want: []string{"302 " + srv.URL + "/o/r/wiki", "301 " + srv.URL + "/hop1", "302 " + srv.URL + "/hop2"}},
+		{trace: 5, want: []string{"302 " + srv.URL + "/o/r/wiki", "301 " + srv.URL + "/hop1", "302 " + srv.URL + "/hop2", "200 " + srv.URL + "/elsewhere"}},
+	}
+	for _, tt := range tests {
+		s := NewScanner()
+		s.TraceRedirects = tt.trace
+
+		p, err := checkAll(s, repo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Tracing is only for diagnostics, the first redirect still decides the result
+		if p.Result != ProbeDisabled {
+			t.Errorf("TraceRedirects %d: result = %s, want %s", tt.trace, p.Result, ProbeDisabled)
+		}
+		if strings.Join(p.Redirects, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("TraceRedirects %d: chain = %q, want %q", tt.trace, p.Redirects, tt.want)
+		}
+	}
+}
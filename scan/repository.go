@@ -0,0 +1,189 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const reposPerPage = 100
+
+// Repository represents a Github repository
+type Repository struct {
+	Name     string `json:"name"`
+	URL      string `json:"html_url"`
+	HasWiki  bool   `json:"has_wiki"`
+	Private  bool   `json:"private"`
+	Archived bool   `json:"archived"`
+	Fork     bool   `json:"fork"`
+	// public, private or internal, when the listing says
+	Visibility string `json:"visibility"`
+
+	PushedAt time.Time `json:"pushed_at"`
+}
+
+// Whether a repository is public, going by its visibility when the listing has one, as internal repositories
+// on Enterprise accounts are neither public nor quite private
+func (r Repository) public() bool {
+	if r.Visibility != "" {
+		return r.Visibility == "public"
+	}
+
+	return !r.Private
+}
+
+// Repository visibilities Scanner.Visibility can pick
+const (
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+	VisibilityAll     = "all"
+)
+
+// Whether private repositories a token can see are scanned, with VisibilityPrivate or VisibilityAll
+func (s *Scanner) includesPrivate() bool {
+	return s.Visibility != VisibilityPublic
+}
+
+// Whether a listed repository has the visibility the scanner asks for
+func (s *Scanner) scannable(r Repository) bool {
+	switch s.Visibility {
+	case VisibilityAll:
+		return true
+	case VisibilityPrivate:
+		return !r.public()
+	default:
+		return r.public()
+	}
+}
+
+// Builds a GitHub API request, which getAPIClient authenticates
+func newAPIRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	return req, nil
+}
+
+var linkRelPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="([^"]+)"`)
+
+// Gets the URL for a given rel from a Link header, or "" if it's not there
+func linkURL(header http.Header, rel string) string {
+	for _, match := range linkRelPattern.FindAllStringSubmatch(header.Get("Link"), -1) {
+		if match[2] == rel {
+			return match[1]
+		}
+	}
+
+	return ""
+}
+
+// Gets a single repository by its owner/repo name
+func (s *Scanner) getRepository(ctx context.Context, fullName string) (Repository, error) {
+	owner, name, _ := strings.Cut(fullName, "/")
+	req, err := newAPIRequest(ctx, http.MethodGet, fmt.Sprintf("%s/repos/%s/%s", s.APIURL, url.PathEscape(owner), url.PathEscape(name)), nil)
+	if err != nil {
+		return Repository{}, err
+	}
+
+	resp, err := s.getAPIClient().Do(req)
+	if err != nil {
+		return Repository{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Repository{}, NewHTTPError("fetch repository", resp)
+	}
+
+	var repo Repository
+	if err := json.NewDecoder(resp.Body).Decode(&repo); err != nil {
+		return Repository{}, err
+	}
+
+	return repo, nil
+}
+
+// Gets all repositories for a given organization
+//
+// When the listing comes back empty, the account is listed once more the other way, as an organization or as a
+// user, before taking it that there's nothing there. A wrong guess at the type, or an account that was converted
+// to an organization, can leave one of them empty.
+func (s *Scanner) getRepositories(ctx context.Context, orgName string) ([]Repository, error) {
+	kind := s.accountListingKind(ctx, orgName)
+	repos, err := s.listAccountRepositories(ctx, kind, orgName)
+	if err != nil || len(repos) > 0 {
+		return repos, err
+	}
+
+	other := otherAccountKind(kind)
+	retried, err := s.listAccountRepositories(ctx, other, orgName)
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+		return repos, nil
+	} else if err != nil {
+		return retried, err
+	}
+
+	if len(retried) > 0 {
+		s.infof("Listing %s as a %s found no repositories, listing it as a %s found %d", orgName, kind, other, len(retried))
+	}
+	return retried, nil
+}
+
+// Gets every page of a repository listing, or the pages up to the first repository pushed to before
+// PushedSince when it's sorted with the most recently pushed first
+func (s *Scanner) listRepositories(ctx context.Context, url string, byPush bool) ([]Repository, error) {
+	client := s.getAPIClient()
+
+	var repos []Repository
+	for url != "" {
+		resp, err := s.getListingPage(ctx, client, url)
+		if err != nil {
+			return listingFailed(repos, err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return listingFailed(repos, NewHTTPError("fetch repositories", resp))
+		}
+
+		var page []Repository
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return listingFailed(repos, err)
+		}
+		for _, repo := range page {
+			if s.scannable(repo) {
+				repos = append(repos, repo)
+			}
+		}
+		if s.MaxRepos > 0 && len(repos) >= s.MaxRepos {
+			return repos[:s.MaxRepos], nil
+		}
+		// The rest are older still, and would all be filtered out
+		if byPush && !s.PushedSince.IsZero() && len(page) > 0 && pushedBefore(page[len(page)-1], s.PushedSince) {
+			break
+		}
+
+		// Follow pagination until there's no next page
+		url = linkURL(resp.Header, "next")
+		if url != "" {
+			if err := s.politeDelay(ctx); err != nil {
+				return listingFailed(repos, err)
+			}
+		}
+	}
+
+	return repos, nil
+}
@@ -0,0 +1,85 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// Bounds for how long to wait between probe retries
+const (
+	probeRetryBase = 500 * time.Millisecond
+	probeRetryMax  = 10 * time.Second
+)
+
+// Whether a failed request is likely to work if it's tried again
+//
+// Anything that says something about the wiki, such as a 404, a redirect or a 429 (which the throttle handles),
+// is an answer rather than a failure.
+func transientFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		var netErr net.Error
+		var dnsErr *net.DNSError
+		var opErr *net.OpError
+		return errors.As(err, &netErr) && netErr.Timeout() ||
+			errors.As(err, &dnsErr) ||
+			errors.As(err, &opErr) ||
+			errors.Is(err, syscall.ECONNRESET) ||
+			errors.Is(err, io.EOF) ||
+			errors.Is(err, io.ErrUnexpectedEOF)
+	}
+
+	return resp.StatusCode >= 500
+}
+
+// Sends a probe, trying again with backoff after transient failures
+func (s *Scanner) doProbeWithRetries(client *http.Client, req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := s.doProbe(client, req)
+		if attempt > s.ProbeRetries || req.Context().Err() != nil || !transientFailure(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			drainAndClose(resp)
+		}
+
+		if err := sleepContext(req.Context(), s.BackoffJitter.Backoff(attempt, probeRetryBase, probeRetryMax)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Times a page of a repository listing is fetched again after a transient failure, before the listing fails
+const listPageRetries = 3
+
+// Fetches a page of a repository listing, trying it again with backoff after connection errors and 5xx responses
+//
+// Rate limits are waited out by the token transport, which sends the same request again, so retrying here
+// always asks for the page that failed rather than moving on to the next one.
+func (s *Scanner) getListingPage(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		req, err := newAPIRequest(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		if attempt > listPageRetries || ctx.Err() != nil || !transientFailure(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			s.debugf("Fetching %s failed with %s, trying again", url, resp.Status)
+			drainAndClose(resp)
+		} else {
+			s.debugf("Fetching %s failed, trying again: %v", url, err)
+		}
+
+		if err := sleepContext(ctx, s.BackoffJitter.Backoff(attempt, probeRetryBase, probeRetryMax)); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestListingResumesFailedPages(t *testing.T) {
+	// Page 2 fails with a 502 once, page 3 drops the connection and is then rate limited before each works
+	failures := map[string][]string{
+		"2": {"502"},
+		"3": {"reset", "ratelimit"},
+	}
+	var mu sync.Mutex
+	var requested []string
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		mu.Lock()
+		requested = append(requested, page)
+		var failure string
+		if f := failures[page]; len(f) > 0 {
+			failure, failures[page] = f[0], f[1:]
+		}
+		mu.Unlock()
+
+		switch failure {
+		case "502":
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		case "reset":
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			conn.Close()
+			return
+		case "ratelimit":
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix()-1, 10))
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message": "API rate limit exceeded"}`)
+			return
+		}
+
+		if n, _ := strconv.Atoi(page); n < 3 {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/repos?page=%d>; rel="next"`, srv.URL, n+1))
+		}
+		fmt.Fprintf(w, `[{"name": "repo%s", "visibility": "public"}]`, page)
+	}))
+	defer srv.Close()
+
+	repos, err := NewScanner().listRepositories(context.Background(), srv.URL+"/repos?page=1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, repo := range repos {
+		names = append(names, repo.Name)
+	}
+	if want := []string{"repo1", "repo2", "repo3"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("listed %v, want %v", names, want)
+	}
+	if want := []string{"1", "2", "2", "3", "3", "3"}; !reflect.DeepEqual(requested, want) {
+		t.Errorf("pages were requested %v, want %v", requested, want)
+	}
+}
+
+func TestListingGivesUpOnPersistentFailures(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<%s/repos?page=2>; rel="next"`, srv.URL))
+		fmt.Fprint(w, `[{"name": "repo1", "visibility": "public"}]`)
+	}))
+	defer srv.Close()
+
+	repos, err := NewScanner().listRepositories(context.Background(), srv.URL+"/repos?page=1", false)
+	if err == nil {
+		t.Fatal("listing a page that always fails succeeded")
+	}
+	if len(repos) != 1 || repos[0].Name != "repo1" {
+		t.Errorf("got %v along with the error, want the first page's repository", repos)
+	}
+}
+
+func TestProbeRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		retries  int
+		failures []int
+		want     int
+		attempts int
+	}{
+		{name: "fails twice then works", retries: 2, failures: []int{502, 503}, want: 200, attempts: 3},
+		{name: "gives up after the retries", retries: 1, failures: []int{500, 500}, want: 500, attempts: 2},
+		{name: "no retries", retries: 0, failures: []int{502}, want: 502, attempts: 1},
+		{name: "not found is an answer", retries: 2, failures: []int{404}, want: 404, attempts: 1},
+		{name: "unauthorized is an answer", retries: 2, failures: []int{401}, want: 401, attempts: 1},
+		{name: "redirect is an answer", retries: 2, failures: []int{302}, want: 302, attempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner()
+			s.ProbeRetries = tt.retries
+			// Full jitter keeps the test quick while still going through the backoff
+			s.BackoffJitter = JitterFull
+			attempts := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				if attempts <= len(tt.failures) {
+					if status := tt.failures[attempts-1]; status == http.StatusFound {
+						http.Redirect(w, r, "/login", status)
+					} else {
+						w.WriteHeader(status)
+					}
+					return
+				}
+				w.Write([]byte("ok"))
+			}))
+			defer srv.Close()
+
+			resp, err := s.probe(context.Background(), s.NewClient(), srv.URL+"/o/r/wiki")
+			if err != nil {
+				t.Fatal(err)
+			}
+			drainAndClose(resp)
+
+			if resp.StatusCode != tt.want || attempts != tt.attempts {
+				t.Errorf("got %d after %d attempts, want %d after %d", resp.StatusCode, attempts, tt.want, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestProbeRetriesConnectionResets(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	s := NewScanner()
+	s.ProbeRetries = 2
+	resp, err := s.probe(context.Background(), s.NewClient(), srv.URL+"/o/r/wiki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainAndClose(resp)
+	if resp.StatusCode != http.StatusOK || attempts != 2 {
+		t.Errorf("got %d after %d attempts, want 200 after the dropped connection was retried", resp.StatusCode, attempts)
+	}
+}
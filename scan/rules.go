@@ -0,0 +1,135 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Rule is a named regexp that flags a wiki when its landing page matches
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// Loads a rules file, in the order the rules are in it, compiling every pattern up front so a bad one stops the
+// scan before it starts
+//
+// The file is a flat YAML mapping of rule names to patterns, one per line, such as
+//
+//	internal-docs: 'Moved to https://wiki\.example\.com'
+//
+// Patterns can be plain, single quoted or double quoted, and blank lines and # comments are skipped. Nested
+// YAML isn't understood.
+func LoadRules(path string) ([]Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []Rule
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || line == "---" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%s:%d: expected name: pattern", path, n)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%s:%d: rule %q is defined twice", path, n, name)
+		}
+		seen[name] = true
+
+		pattern, err := parseRuleValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: rule %q: %w", path, n, name, err)
+		}
+		if pattern == "" {
+			return nil, fmt.Errorf("%s:%d: rule %q has no pattern", path, n, name)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: rule %q: %w", path, n, name, err)
+		}
+
+		rules = append(rules, Rule{Name: name, Pattern: re})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// Gets the pattern out of a YAML scalar, unquoting it or cutting off a trailing comment
+func parseRuleValue(value string) (string, error) {
+	if value == "" || (value[0] != '"' && value[0] != '\'') {
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		return value, nil
+	}
+
+	// Find the closing quote, which a backslash escapes in double quotes and doubling it does in single ones
+	quote := value[0]
+	end := -1
+	for i := 1; i < len(value); i++ {
+		if quote == '"' && value[i] == '\\' {
+			i++
+			continue
+		}
+		if value[i] == quote {
+			if quote == '\'' && i+1 < len(value) && value[i+1] == '\'' {
+				i++
+				continue
+			}
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return "", fmt.Errorf("unterminated quoted pattern")
+	}
+	if rest := strings.TrimSpace(value[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected %q after the quoted pattern", rest)
+	}
+
+	if quote == '"' {
+		return strconv.Unquote(value[:end+1])
+	}
+	return strings.ReplaceAll(value[1:end], "''", "'"), nil
+}
+
+// Gets the first rule the landing page matches, if any
+func (s *Scanner) matchRule(body string) (string, bool) {
+	for _, r := range s.Rules {
+		if r.Pattern.MatchString(body) {
+			return r.Name, true
+		}
+	}
+
+	return "", false
+}
+
+// With Rules, a landing page that matches one of the rules is flagged with the rule's name
+func stageRules(ctx context.Context, c *wikiCheck) (bool, error) {
+	name, ok := c.s.matchRule(c.body)
+	if !ok {
+		return false, nil
+	}
+
+	c.probe.Result = ProbeRuleMatch
+	c.probe.Rule = name
+	return true, nil
+}
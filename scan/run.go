@@ -0,0 +1,419 @@
+package scan
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Generates a random scan ID
+func newScanID() string {
+	b := make([]byte, 8)
+	if _, err := crand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// How many wikis in a row have to be disabled, with none that aren't, before an account's wikis count as
+// disabled account-wide
+const orgDisabledThreshold = 3
+
+// failedProbe is a probe that errored, kept for the retry pass
+type failedProbe struct {
+	account  string
+	repo     Repository
+	reporter Reporter
+}
+
+// Run is what's shared by every account scanned in one run, which Scan can be called for from several
+// goroutines at once
+//
+// Get one from Scanner.NewRun, and set what it records to before the first scan.
+type Run struct {
+	// Where every checked repository is recorded, nil for nowhere
+	Inventory *Inventory
+	// State kept between runs, nil for none
+	Store *Store
+	// Repositories an interrupted run already checked, nil for none
+	Checkpoint *Checkpoint
+
+	Summary  *Summary
+	Manifest *Manifest
+
+	// Whether errored probes are held back for RetryFailures rather than reported
+	RetryFailed bool
+
+	s *Scanner
+	// Client wiki probes go out on
+	client *http.Client
+	// Probes run at once, and a slot for each of them shared by every account's pool
+	concurrency int
+	probeSlots  chan struct{}
+
+	failed []failedProbe
+	// Repositories already checked by an earlier account, or earlier in the same one, unless AllowDuplicates
+	seen map[string]bool
+
+	// Guards everything above that changes as accounts are scanned, as several can be scanned at once
+	mu sync.Mutex
+}
+
+// Checks a repository's wiki with the run's client
+func (run *Run) checkWiki(ctx context.Context, repo Repository) (Probe, error) {
+	return run.s.runStages(ctx, run.client, run.s.Stages, repo)
+}
+
+// Whether a repository was already checked in this run, marking it as checked if it wasn't
+func (run *Run) duplicate(repo Repository) bool {
+	if run.s.AllowDuplicates {
+		return false
+	}
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	key := strings.ToLower(strings.TrimSuffix(repo.URL, "/"))
+	if run.seen[key] {
+		return true
+	}
+	run.seen[key] = true
+
+	return false
+}
+
+// Gets the level to log a probe's error at, only actual errors are worse than a warning
+func errorLevel(p Probe) string {
+	if p.Result == ProbeError {
+		return "error"
+	}
+
+	return "warn"
+}
+
+// Whether a probe didn't get an answer and is worth trying again
+func retryable(p Probe) bool {
+	return p.Result == ProbeError || p.Result == ProbeThrottled
+}
+
+// Records and reports the outcome of checking a repository
+func (run *Run) record(ctx context.Context, account string, repo Repository, p Probe, reporter Reporter) {
+	f := Finding{
+		Account:      account,
+		Repo:         repo.Name,
+		RepoURL:      repo.URL,
+		Private:      repo.Private,
+		URL:          p.URL,
+		Result:       p.Result,
+		Redirects:    p.Redirects,
+		SignedInOnly: p.SignedInOnly,
+		Rule:         p.Rule,
+		CheckedAt:    time.Now(),
+	}
+
+	// Empty wikis don't have any history to look at
+	if run.s.WikiGitLog && p.Result == ProbeWriteable {
+		commit, err := run.s.getLastWikiCommit(ctx, repo.URL)
+		if err != nil {
+			run.s.logError("warn", "fetch wiki history", account, repo.Name, err)
+		} else {
+			f.LastAuthor = commit.Author
+			f.LastEdited = commit.Date
+		}
+	}
+
+	// Findings from accounts scanned at once each go out whole, one after another
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	run.Summary.Add(p)
+	run.Manifest.Record(account, p.Result)
+	if err := run.Inventory.Record(account, repo, p.Result); err != nil {
+		run.s.logError("error", "write inventory", account, repo.Name, err)
+	}
+	run.Store.Record(repo, p.Result)
+
+	run.s.Metrics.scanned(f)
+	run.s.report(reporter, f)
+
+	if err := run.Checkpoint.Record(repo); err != nil {
+		run.s.logError("warn", "write checkpoint", account, repo.Name, err)
+	}
+}
+
+// Scans an organization, or another target, for repositories with wikis, handing each finding to reporter
+//
+// The error is why the target couldn't be listed, or the context's when its scan was cut off. A listing that
+// AllowPartial scans what it got of isn't one.
+func (run *Run) Scan(ctx context.Context, orgName string, reporter Reporter) error {
+	if orgName == "" {
+		run.s.warnf("Organization name cannot be empty")
+		return nil
+	}
+
+	ctx, endAccount := run.s.tracer().StartAccount(ctx, orgName)
+	probed := 0
+	var listErr error
+	defer func() { endAccount(probed, listErr) }()
+
+	if run.s.AccountTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, run.s.AccountTimeout)
+		defer cancel()
+	}
+
+	repos, coverage, ok := run.listTarget(ctx, orgName, &listErr)
+	if !ok {
+		return listErr
+	}
+
+	// The store is only read here, before the workers start, as recording below changes it
+	unchanged := make([]bool, len(repos))
+	duplicates := make([]bool, len(repos))
+	resumed := make([]bool, len(repos))
+	var toProbe []Repository
+	for i, repo := range repos {
+		switch {
+		case run.duplicate(repo):
+			duplicates[i] = true
+		case run.Checkpoint.Done(repo):
+			resumed[i] = true
+		case run.unchanged(repo):
+			unchanged[i] = true
+		default:
+			toProbe = append(toProbe, repo)
+		}
+	}
+
+	// Outcomes come back in order, so everything below runs as if the probes were made one at a time
+	var orgDisabled atomic.Bool
+	pool := run.startProbePool(ctx, orgName, toProbe, &orgDisabled)
+	defer pool.stop()
+
+	skipped, duplicated, resumedCount := 0, 0, 0
+	// Wikis disabled so far, and whether any wiki wasn't, to spot accounts that turned them all off
+	disabled := 0
+	enabledSeen := false
+	for i, repo := range repos {
+		if ctx.Err() != nil {
+			run.cutOff(ctx, coverage, i, len(repos))
+			break
+		}
+
+		if duplicates[i] {
+			duplicated++
+			continue
+		}
+		if resumed[i] {
+			resumedCount++
+			continue
+		}
+		if unchanged[i] {
+			skipped++
+			continue
+		}
+
+		if run.s.Progress != nil {
+			run.s.Progress(i+1, len(repos), repo)
+		}
+		outcome, ok := pool.next()
+		if !ok {
+			run.cutOff(ctx, coverage, i, len(repos))
+			break
+		}
+
+		if outcome.skipped || (repo.HasWiki && run.s.SkipOrgDisabled && !enabledSeen && disabled >= orgDisabledThreshold) {
+			run.record(ctx, orgName, repo, Probe{Result: ProbeOrgDisabled, URL: repo.URL}, reporter)
+			continue
+		}
+
+		p, err := outcome.p, outcome.err
+		probed++
+		if ctx.Err() != nil {
+			// The probe was cut off rather than failing, so it's left unchecked
+			run.cutOff(ctx, coverage, i, len(repos))
+			break
+		}
+		if repo.HasWiki {
+			coverage.Probed++
+		}
+		if err != nil {
+			run.s.logError(errorLevel(p), "probe", orgName, repo.Name, err)
+			if run.RetryFailed && retryable(p) {
+				run.mu.Lock()
+				run.failed = append(run.failed, failedProbe{account: orgName, repo: repo, reporter: reporter})
+				run.mu.Unlock()
+				continue
+			}
+		}
+		run.record(ctx, orgName, repo, p, reporter)
+
+		switch p.Result {
+		case ProbeDisabled, ProbeAdminDisabled:
+			disabled++
+			if disabled == orgDisabledThreshold && !enabledSeen && run.s.SkipOrgDisabled {
+				run.s.infof("Wikis look disabled for all of %s, skipping the rest of its wikis", orgName)
+				orgDisabled.Store(true)
+			}
+		case ProbeNoWiki, ProbeError, ProbeUnexpected, ProbeThrottled, ProbeInvalidURL:
+			// These say nothing about whether the account turned wikis off
+		default:
+			enabledSeen = true
+		}
+	}
+	if skipped > 0 {
+		run.s.infof("Skipped %d repositories in %s that haven't been pushed to since the last run", skipped, orgName)
+	}
+	if resumedCount > 0 {
+		run.s.infof("Skipped %d repositories in %s that were checked before the scan was interrupted", resumedCount, orgName)
+	}
+	if duplicated > 0 {
+		run.s.infof("Skipped %d repositories in %s that were already checked in this run", duplicated, orgName)
+	}
+
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	if err := run.Store.Save(); err != nil {
+		run.s.logError("error", "save state", orgName, "", err)
+	}
+
+	return ctx.Err()
+}
+
+// Whether a repository hasn't been pushed to since the last run's state was saved
+func (run *Run) unchanged(repo Repository) bool {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	return run.Store.Unchanged(repo)
+}
+
+// Lists a target's repositories as they'll be probed, on the probe host and filtered, counting them in the
+// manifest, or false when there's nothing to scan
+//
+// The listing's error, even when it's only partial, is kept in listErr for the trace.
+func (run *Run) listTarget(ctx context.Context, orgName string, listErr *error) ([]Repository, *accountCoverage, bool) {
+	t, err := parseAccountInput(orgName)
+	if err != nil {
+		*listErr = err
+		run.s.logError("error", "parse input", orgName, "", err)
+		return nil, nil, false
+	}
+
+	run.mu.Lock()
+	coverage := run.Manifest.account(ctx, run.s, orgName, t)
+	run.mu.Unlock()
+
+	repos, err := t.repositories(ctx, run.s)
+	*listErr = err
+	var partial *partialListingError
+	if err != nil && ctx.Err() != nil {
+		run.cutOff(ctx, coverage, 0, len(repos))
+		return nil, coverage, false
+	} else if errors.As(err, &partial) && run.s.AllowPartial {
+		coverage.Partial = true
+		run.s.logError("warn", "list repositories", orgName, "", err)
+		run.s.warnf("Scanning the %d repositories listed in %s before the listing failed", len(repos), orgName)
+	} else if err != nil {
+		run.s.logError("error", "list repositories", orgName, "", err)
+		return nil, coverage, false
+	}
+
+	if t.kind != targetURL {
+		for i := range repos {
+			repos[i].URL = run.s.onProbeHost(repos[i].URL)
+		}
+	}
+
+	if kept := run.s.filterRepositories(repos); len(kept) < len(repos) {
+		run.s.infof("Filtered out %d of the %d repositories in %s", len(repos)-len(kept), len(repos), orgName)
+		repos = kept
+	}
+
+	coverage.Repositories = len(repos)
+	for _, repo := range repos {
+		if repo.HasWiki {
+			coverage.WithWiki++
+		}
+	}
+
+	run.s.Metrics.listed(len(repos))
+	return repos, coverage, true
+}
+
+// Marks an account as partly scanned after running out of time, or being interrupted, with done of its total
+// repositories checked
+func (run *Run) cutOff(ctx context.Context, coverage *accountCoverage, done, total int) {
+	run.s.logError("warn", "scan account", coverage.Account, "", fmt.Errorf("stopped after %d of %d repositories: %w", done, total, ctx.Err()))
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	run.Summary.Partial = append(run.Summary.Partial, coverage.Account)
+	coverage.Partial = true
+}
+
+// Probes every repository that errored and was held back by RetryFailed once more, recording whatever comes
+// back this time
+func (run *Run) RetryFailures(ctx context.Context) {
+	if len(run.failed) == 0 {
+		return
+	}
+
+	run.s.infof("Retrying %d failed probes", len(run.failed))
+	recovered := 0
+	for _, f := range run.failed {
+		if run.s.politeDelay(ctx) != nil {
+			break
+		}
+		probeCtx, endProbe := run.s.tracer().StartProbe(ctx, f.account, f.repo)
+		p, err := run.checkWiki(probeCtx, f.repo)
+		endProbe(p, err)
+		if err != nil {
+			run.s.logError(errorLevel(p), "retry probe", f.account, f.repo.Name, err)
+		} else {
+			recovered++
+		}
+		run.record(ctx, f.account, f.repo, p, f.reporter)
+	}
+	run.s.infof("Recovered %d of %d failed probes", recovered, len(run.failed))
+	run.failed = nil
+
+	if err := run.Store.Save(); err != nil {
+		run.s.logError("error", "save state", "", "", err)
+	}
+}
+
+// Lists a target's repositories as they'll be probed, on the probe host, filtered and without the ones already
+// checked in this run, counting them in the manifest
+//
+// The error is why the target couldn't be listed. A listing that AllowPartial scans what it got of isn't one.
+func (run *Run) List(ctx context.Context, orgName string) ([]Repository, error) {
+	var listErr error
+	repos, _, ok := run.listTarget(ctx, orgName, &listErr)
+	if !ok {
+		return nil, listErr
+	}
+
+	var kept []Repository
+	for _, repo := range repos {
+		if !run.duplicate(repo) {
+			kept = append(kept, repo)
+		}
+	}
+
+	return kept, nil
+}
+
+// Reports a finding, logging rather than stopping the scan if the output fails
+func (s *Scanner) report(reporter Reporter, f Finding) {
+	if err := reporter.Report(f); err != nil {
+		s.logError("error", "report", f.Account, f.Repo, err)
+	}
+}
@@ -0,0 +1,278 @@
+// Package scan finds GitHub wikis that anyone can edit, listing the repositories of accounts and other targets
+// and checking each of their wikis
+//
+// A Scanner carries every setting and hands back what it found rather than printing it, so it can be embedded
+// in other programs as well as run by the gitwiki command.
+package scan
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Scanner lists and checks the wikis of accounts and other targets
+//
+// Create one with NewScanner, which fills in the defaults, and change its fields before scanning with it. Wikis
+// are probed on the host in each repository's URL, so pointing APIURL and the repositories at a test server
+// checks it instead of GitHub.
+type Scanner struct {
+	// Client wiki probes go out on, which mustn't follow redirects as they're part of what's classified (nil is
+	// one from NewClient)
+	Client *http.Client
+	// Probes run at once, shared by every account being scanned
+	Concurrency int
+	// Longest an account's listing and probes may take (0 is no limit)
+	AccountTimeout time.Duration
+	// Whether to scan what was listed when a listing fails partway
+	AllowPartial bool
+	// Whether to stop probing an account once its wikis look disabled account-wide
+	SkipOrgDisabled bool
+	// Whether to check a repository again when it's listed more than once
+	AllowDuplicates bool
+
+	// Where API requests go, moved to a GitHub Enterprise Server by SetGitHubURL
+	APIURL     string
+	GraphQLURL string
+	// Host that wiki probes go to, and that connections are warmed up to
+	ProbeHost string
+	// Headers added to every API request
+	APIHeaders http.Header
+	// User-Agent sent on every request that doesn't set its own, unless BrowserHeaders picks one
+	UserAgent string
+	// Picks the proxy for a request (nil is the environment's)
+	Proxy func(*http.Request) (*url.URL, error)
+	// Where API responses are cached with their ETags ("" is no caching)
+	APICacheDir string
+
+	// Which repositories are scanned by visibility: VisibilityPublic, VisibilityPrivate or VisibilityAll
+	Visibility string
+	// Most repositories listed for an account, the most recently pushed to (0 is no limit)
+	MaxRepos int
+	// Repositories to look up in each account instead of listing it, as names or owner/name
+	OnlyRepos []string
+	// Most search results to scan per query
+	SearchMax int
+	// Whether to look up the actual type of an org: or user: account whose listing isn't found
+	FixAccountType bool
+	// Filters every listed repository has to pass before it's probed, on top of the ones below
+	Filters []Filter
+	// Whether archived repositories and forks are left out
+	SkipArchived bool
+	SkipForks    bool
+	// Repositories last pushed to before this are left out (zero keeps them all)
+	PushedSince time.Time
+
+	// Detection stages run for every wiki, in order
+	Stages []Stage
+	// Whether to classify wikis from the landing page alone, without the writeable probe
+	FirstPageOnly bool
+	// Whether to ignore the empty wiki markers and rely on the writeable probe
+	NoFirstPage bool
+	// Markers of an empty wiki on its landing page, any of which is enough
+	FirstPageMarkers []string
+	// Whether empty wiki markers are checked against the wiki's git repository
+	VerifyEmpty bool
+	// Smallest landing page body worth classifying
+	MinBodySize int
+	// How much of a landing page to ask for with a Range header (0 gets all of it)
+	RangeBytes int
+	// Most of a landing page to read (0 is no limit)
+	MaxBodyBytes int
+	// Rules the landing page is matched against, in order
+	Rules []Rule
+	// Whether wikis with pages that fail the writeable probe are reported as readable
+	ReportReadable bool
+	// Page the writeable probe asks for ("" picks a new random one for each wiki)
+	TestPage string
+	// Whether to look up who last edited writeable wikis, which needs git
+	WikiGitLog bool
+	// Whether repository URLs that aren't https owner/repo URLs on one of ProviderHosts are left unprobed
+	StrictURLs    bool
+	ProviderHosts []string
+	// How many redirects to follow when recording redirect chains (0 is off)
+	TraceRedirects int
+
+	// Whether wiki probes should look like they come from a browser
+	BrowserHeaders bool
+	// Whether wiki probes go out logged out even when there's a token
+	AnonymousProbes bool
+	// Longest a single probe may take, reading its body included (0 is no limit)
+	RequestTimeout time.Duration
+	// How many times to retry a probe after a transient failure
+	ProbeRetries int
+	// How retry and rate limit waits are randomized
+	BackoffJitter Jitter
+	// Pause between probes and between the pages of a listing, shared by every worker, and the most random time
+	// added to each
+	Delay       time.Duration
+	DelayJitter time.Duration
+
+	// Identifies the scan in its manifest
+	ScanID string
+
+	// Where diagnostics go (nil is slog's default logger)
+	Logger *slog.Logger
+	// Gets the operational errors that happened while doing op, at level warn or error, in place of logging them
+	OnError func(level, op, account, repo string, err error)
+	// Counts what the scan has done (nil doesn't count anything)
+	Metrics *Metrics
+	// Records spans for account scans and their probes (nil doesn't record any)
+	Tracer Tracer
+	// Called as each repository of an account is checked, with its place in the account's listing
+	Progress func(n, total int, repo Repository)
+
+	// Transport shared by every client, so connections are reused, and what guards growing its idle connections
+	transport *http.Transport
+	idleMu    sync.Mutex
+	// Tokens for API requests, and for wiki probes to ProbeHost
+	tokens *tokenPool
+	// Holds back every probe after the wiki host throttles one
+	throttle *probeThrottle
+	// The Enterprise Server repository URLs are moved onto, nil for github.com
+	enterpriseHost *url.URL
+	// CA certificates trusted on top of the system's, and whether certificates go unchecked, for git
+	caCertFile         string
+	insecureSkipVerify bool
+
+	// The user the primary token authenticates as, looked up the first time it's needed
+	viewer struct {
+		mu      sync.Mutex
+		checked bool
+		login   string
+	}
+	// Earliest the next request may go out, shared by every worker so Delay spaces out all requests rather than
+	// each worker's own
+	delayGate struct {
+		mu   sync.Mutex
+		next time.Time
+	}
+}
+
+// Creates a scanner for github.com with the gitwiki command's defaults
+func NewScanner() *Scanner {
+	s := &Scanner{
+		Concurrency:      defaultConcurrency,
+		APIURL:           "https://api.github.com",
+		GraphQLURL:       "https://api.github.com/graphql",
+		ProbeHost:        "https://github.com",
+		APIHeaders:       http.Header{},
+		UserAgent:        "gitwiki",
+		Visibility:       VisibilityPublic,
+		SearchMax:        searchResultCap,
+		Stages:           AllStages(),
+		FirstPageMarkers: []string{WikiFirstPageMarker},
+		MinBodySize:      512,
+		MaxBodyBytes:     1 << 20,
+		ProviderHosts:    []string{"github.com"},
+		RequestTimeout:   30 * time.Second,
+		ProbeRetries:     2,
+		BackoffJitter:    JitterFull,
+		ScanID:           newScanID(),
+		tokens:           &tokenPool{},
+		throttle:         &probeThrottle{},
+	}
+	s.transport = http.DefaultTransport.(*http.Transport).Clone()
+	s.transport.Proxy = s.proxy
+
+	return s
+}
+
+// Picks the proxy for a request with Proxy, or from the environment
+func (s *Scanner) proxy(req *http.Request) (*url.URL, error) {
+	if s.Proxy != nil {
+		return s.Proxy(req)
+	}
+
+	return http.ProxyFromEnvironment(req)
+}
+
+// Adds GitHub tokens for API requests, skipping blanks and ones the scanner already has
+//
+// The first one is also sent on wiki probes to ProbeHost, unless AnonymousProbes is set, so private and SSO
+// protected wikis aren't missed. The rest take over when its rate limit runs out.
+func (s *Scanner) AddTokens(values ...string) {
+	s.tokens.add(values...)
+}
+
+// Gets a run with the scanner's options, for scanning one or more targets
+func (s *Scanner) NewRun() *Run {
+	concurrency := s.Concurrency
+	if concurrency < 1 {
+		concurrency = defaultConcurrency
+	}
+
+	client := s.Client
+	if client == nil {
+		client = s.NewClient()
+	}
+
+	s.setIdleConnsPerHost(concurrency)
+
+	return &Run{
+		Summary:     NewSummary(),
+		Manifest:    NewManifest(s.ScanID, false),
+		s:           s,
+		client:      client,
+		concurrency: concurrency,
+		probeSlots:  make(chan struct{}, concurrency),
+		seen:        make(map[string]bool),
+	}
+}
+
+// Lists a target's repositories as a scan would probe them, on the probe host and filtered
+//
+// A listing that fails partway comes back with what was listed before it did, along with the error.
+func (s *Scanner) ListRepositories(ctx context.Context, target string) ([]Repository, error) {
+	run := s.NewRun()
+
+	var listErr error
+	repos, _, ok := run.listTarget(ctx, target, &listErr)
+	if !ok {
+		return nil, listErr
+	}
+
+	return repos, listErr
+}
+
+// Checks one repository's wiki
+func (s *Scanner) CheckWiki(ctx context.Context, repo Repository) (Probe, error) {
+	return s.NewRun().checkWiki(ctx, repo)
+}
+
+// Scans a target, getting a finding for each repository that was checked
+//
+// Probes that error still get a finding, with ProbeError or ProbeThrottled as its result. The error is the
+// listing's when the target couldn't be listed, or the context's when the scan was cut off.
+func (s *Scanner) ScanAccount(ctx context.Context, target string) ([]Finding, error) {
+	if target == "" {
+		return nil, errors.New("target cannot be empty")
+	}
+
+	var c findingCollector
+	err := s.NewRun().Scan(ctx, target, &c)
+
+	return c.findings, err
+}
+
+// findingCollector keeps the findings reported to it
+type findingCollector struct {
+	findings []Finding
+}
+
+func (c *findingCollector) Report(f Finding) error {
+	c.findings = append(c.findings, f)
+	return nil
+}
+
+func (c *findingCollector) Flush() error {
+	return nil
+}
+
+func (c *findingCollector) Close() error {
+	return nil
+}
@@ -0,0 +1,349 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripFunc stubs a transport with a function
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestAPIHeaders(t *testing.T) {
+	headers := http.Header{"X-Gateway-Route": {"github"}, "X-Team": {"security", "audit"}}
+
+	var sent *http.Request
+	stub := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sent = req
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	})
+	original, err := http.NewRequest(http.MethodGet, "https://api.github.com/users/acme/repos", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original.Header.Set("X-Gateway-Route", "replaced")
+	if _, err := (&headerTransport{base: stub, headers: headers}).RoundTrip(original); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := sent.Header.Values("X-Gateway-Route"); len(got) != 1 || got[0] != "github" {
+		t.Errorf("X-Gateway-Route = %q, want just github", got)
+	}
+	if got := sent.Header.Values("X-Team"); strings.Join(got, ",") != "security,audit" {
+		t.Errorf("X-Team = %q, want security and audit", got)
+	}
+	if original.Header.Get("X-Gateway-Route") != "replaced" || original.Header.Get("X-Team") != "" {
+		t.Errorf("the caller's request was modified: %v", original.Header)
+	}
+}
+
+func TestAPIHeadersComposeWithTokens(t *testing.T) {
+	s := NewScanner()
+	s.APIHeaders = http.Header{"X-Gateway-Route": {"github"}}
+	s.AddTokens("secret")
+
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	resp, err := s.getAPIClient().Get(srv.URL + "/rate_limit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got.Get("X-Gateway-Route") != "github" {
+		t.Errorf("API request was sent without the APIHeaders: %v", got)
+	}
+	if got.Get("Authorization") != "Bearer secret" {
+		t.Errorf("API request's Authorization = %q, want the token", got.Get("Authorization"))
+	}
+}
+
+func TestBrowserHeaders(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		s := newTestScanner()
+		s.BrowserHeaders = enabled
+
+		var probes []http.Header
+		record := func(next http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				probes = append(probes, r.Header.Clone())
+				next(w, r)
+			}
+		}
+		srv := wikiServer(t, record(respond(http.StatusOK, populatedWikiBody)), record(respond(http.StatusOK, "edit")))
+		repo := Repository{Name: "o/r", URL: srv.URL + "/o/r", HasWiki: true}
+		if _, err := checkAll(s, repo); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(probes) != 2 {
+			t.Fatalf("browser headers %t: got %d probes, want the landing page and the test page", enabled, len(probes))
+		}
+		for i, h := range probes {
+			browser := strings.HasPrefix(h.Get("User-Agent"), "Mozilla/5.0")
+			if browser != enabled || (h.Get("Accept-Language") != "") != enabled || (h.Get("Sec-Fetch-Mode") == "navigate") != enabled {
+				t.Errorf("browser headers %t: probe %d was sent with %v", enabled, i, h)
+			}
+			if enabled && !strings.HasPrefix(h.Get("Accept"), "text/html") {
+				t.Errorf("probe %d accepts %q, want HTML first", i, h.Get("Accept"))
+			}
+		}
+	}
+}
+
+// Serves a fake GitHub from mux for the rest of the test, with the API on the same host as the wikis, getting a
+// scanner that uses it and doesn't retry probes
+func fakeGitHub(t *testing.T, mux *http.ServeMux) (*Scanner, *httptest.Server) {
+	t.Helper()
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	s := NewScanner()
+	s.APIURL = srv.URL
+	s.ProbeRetries = 0
+
+	return s, srv
+}
+
+// Lists repos as an account's repositories, with their URLs given as paths on the server
+func listing(repos ...Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		listed := make([]Repository, len(repos))
+		for i, repo := range repos {
+			repo.URL = "http://" + r.Host + repo.URL
+			repo.Visibility = "public"
+			listed[i] = repo
+		}
+		json.NewEncoder(w).Encode(listed)
+	}
+}
+
+func TestRetryFailedProbes(t *testing.T) {
+	failures := 1
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/acme/repos", listing(
+		Repository{Name: "flaky", URL: "/acme/flaky", HasWiki: true},
+		Repository{Name: "locked", URL: "/acme/locked", HasWiki: true},
+	))
+	mux.HandleFunc("/acme/flaky/wiki", func(w http.ResponseWriter, r *http.Request) {
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(populatedWikiBody))
+	})
+	mux.HandleFunc("/acme/flaky/wiki/", respond(http.StatusOK, populatedWikiBody))
+	mux.HandleFunc("/acme/locked/wiki", respond(http.StatusOK, populatedWikiBody))
+	mux.Handle("/acme/locked/wiki/", http.RedirectHandler("/login", http.StatusFound))
+	s, _ := fakeGitHub(t, mux)
+
+	var c findingCollector
+	run := s.NewRun()
+	run.RetryFailed = true
+	if err := run.Scan(context.Background(), "acme", &c); err != nil {
+		t.Fatal(err)
+	}
+	if len(run.failed) != 1 || run.failed[0].repo.Name != "flaky" {
+		t.Fatalf("held back %+v for the retry pass, want just flaky", run.failed)
+	}
+	if len(c.findings) != 1 || c.findings[0].Repo != "locked" {
+		t.Fatalf("reported %+v before the retry pass, want just locked", c.findings)
+	}
+
+	run.RetryFailures(context.Background())
+	if len(c.findings) != 2 || c.findings[1].Repo != "flaky" || c.findings[1].Result != ProbeWriteable {
+		t.Fatalf("reported %+v after the retry pass, want flaky as writeable", c.findings)
+	}
+	if run.Summary.Results[ProbeWriteable] != 1 || run.Summary.Results[ProbeError] != 0 || run.Summary.Repositories != 2 {
+		t.Errorf("summary is %+v, want the retried probe counted once as writeable", run.Summary)
+	}
+}
+
+func TestThrottledProbesAreRetried(t *testing.T) {
+	throttled := true
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/acme/repos", listing(Repository{Name: "busy", URL: "/acme/busy", HasWiki: true}))
+	mux.HandleFunc("/acme/busy/wiki", func(w http.ResponseWriter, r *http.Request) {
+		if throttled {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(populatedWikiBody))
+	})
+	mux.Handle("/acme/busy/wiki/", http.RedirectHandler("/login", http.StatusFound))
+	s, _ := fakeGitHub(t, mux)
+
+	var c findingCollector
+	run := s.NewRun()
+	run.RetryFailed = true
+	if err := run.Scan(context.Background(), "acme", &c); err != nil {
+		t.Fatal(err)
+	}
+	if len(run.failed) != 1 || run.failed[0].repo.Name != "busy" || len(c.findings) != 0 {
+		t.Fatalf("held back %+v and reported %+v, want the throttled repo held back for the retry pass", run.failed, c.findings)
+	}
+	if wait := time.Until(s.throttle.until); wait < 25*time.Second {
+		t.Errorf("probes are held back for %s, want the 30s Retry-After", wait)
+	}
+
+	throttled = false
+	s.throttle = &probeThrottle{}
+	run.RetryFailures(context.Background())
+	if run.Summary.Results[ProbeRequiresAuth] != 1 || run.Summary.Results[ProbeThrottled] != 0 {
+		t.Errorf("summary is %+v, want the retried probe counted once as %s", run.Summary.Results, ProbeRequiresAuth)
+	}
+
+	// Without RetryFailed the repository is reported as throttled rather than as a clean wiki
+	throttled = true
+	s.throttle = &probeThrottle{}
+	run = s.NewRun()
+	if err := run.Scan(context.Background(), "acme", &c); err != nil {
+		t.Fatal(err)
+	}
+	if run.Summary.Results[ProbeThrottled] != 1 {
+		t.Errorf("summary is %+v, want the repo counted as %s", run.Summary.Results, ProbeThrottled)
+	}
+}
+
+func TestAccountTimeoutMovesOnToTheNextAccount(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/slow/repos", listing(Repository{Name: "hangs", URL: "/slow/hangs", HasWiki: true}))
+	mux.HandleFunc("/slow/hangs/wiki", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	mux.HandleFunc("/users/fast/repos", listing(Repository{Name: "docs", URL: "/fast/docs", HasWiki: true}))
+	mux.HandleFunc("/fast/docs/wiki", respond(http.StatusOK, populatedWikiBody))
+	mux.HandleFunc("/fast/docs/wiki/", respond(http.StatusOK, populatedWikiBody))
+	s, _ := fakeGitHub(t, mux)
+	s.AccountTimeout = 200 * time.Millisecond
+	run := s.NewRun()
+
+	var slow, fast findingCollector
+	start := time.Now()
+	err := run.Scan(context.Background(), "slow", &slow)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("slow account's scan = %v, want it cut off by its timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("slow account took %s with a 200ms timeout", elapsed)
+	}
+	if len(slow.findings) != 0 {
+		t.Errorf("slow account reported %+v, want nothing for the probe that was cut off", slow.findings)
+	}
+
+	if err := run.Scan(context.Background(), "fast", &fast); err != nil {
+		t.Fatal(err)
+	}
+	if len(fast.findings) != 1 || fast.findings[0].Result != ProbeWriteable {
+		t.Errorf("next account reported %+v, want its writeable wiki", fast.findings)
+	}
+	if fmt.Sprint(run.Summary.Partial) != "[slow]" {
+		t.Errorf("partial accounts = %v, want just slow", run.Summary.Partial)
+	}
+}
+
+func TestCancelledContextAbortsProbe(t *testing.T) {
+	aborted := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			close(aborted)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+	repo := Repository{Name: "o/r", URL: srv.URL + "/o/r", HasWiki: true}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	s := NewScanner()
+	_, err := s.runStages(ctx, s.NewClient(), s.Stages, repo)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("probe on a cancelled context = %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("probe took %s to stop after its context was cancelled", elapsed)
+	}
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Error("the server never saw the request go away")
+	}
+}
+
+func TestRequestTimeoutCutsOffSlowProbes(t *testing.T) {
+	s := newTestScanner()
+	s.RequestTimeout = 100 * time.Millisecond
+
+	release := make(chan struct{})
+	defer close(release)
+	mux := http.NewServeMux()
+	// Never answers in time
+	mux.HandleFunc("/slow-headers", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	})
+	// Answers straight away, then stalls partway through the body
+	mux.HandleFunc("/slow-body", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<p>Welcome"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// The scan's own context has no deadline, so only RequestTimeout can stop these
+	ctx := context.Background()
+
+	start := time.Now()
+	_, err := s.probe(ctx, s.NewClient(), srv.URL+"/slow-headers")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("probe of a server that doesn't answer = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("probe took %s with a %s RequestTimeout", elapsed, s.RequestTimeout)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("the scan's context was done too")
+	}
+
+	start = time.Now()
+	resp, err := s.probe(ctx, s.NewClient(), srv.URL+"/slow-body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("reading a body that stalls = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("reading the body took %s with a %s RequestTimeout", elapsed, s.RequestTimeout)
+	}
+}
@@ -0,0 +1,62 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// The search API never returns more than this many results for a query
+const searchResultCap = 1000
+
+// Gets the public repositories matching a search query across all of GitHub, up to SearchMax of them
+func (s *Scanner) searchRepositories(ctx context.Context, query string) ([]Repository, error) {
+	limit := min(s.SearchMax, searchResultCap)
+	if limit <= 0 {
+		limit = searchResultCap
+	}
+
+	u := fmt.Sprintf("%s/search/repositories?q=%s&per_page=%d", s.APIURL, url.QueryEscape(query), min(limit, reposPerPage))
+
+	client := s.getAPIClient()
+
+	var repos []Repository
+	for u != "" && len(repos) < limit {
+		req, err := newAPIRequest(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return listingFailed(repos, err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return listingFailed(repos, err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return listingFailed(repos, NewHTTPError("search repositories", resp))
+		}
+
+		var page struct {
+			Items []Repository `json:"items"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return listingFailed(repos, err)
+		}
+
+		for _, repo := range page.Items {
+			if s.scannable(repo) && len(repos) < limit {
+				repos = append(repos, repo)
+			}
+		}
+
+		// The search API has its own, much lower, rate limit, which the token pool waits out
+		u = linkURL(resp.Header, "next")
+	}
+
+	return repos, nil
+}
@@ -0,0 +1,76 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func TestSearchRepositories(t *testing.T) {
+	const query = "topic:documentation language:markdown"
+	var pages []int
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search/repositories" || r.URL.Query().Get("q") != query {
+			t.Errorf("searched with %s", r.URL)
+			http.NotFound(w, r)
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		page = max(page, 1)
+		pages = append(pages, page)
+
+		// Two results a page, the second of them private, across three pages
+		if page < 3 {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/search/repositories?q=%s&page=%d>; rel="next"`, srv.URL, url.QueryEscape(query), page+1))
+		}
+		fmt.Fprintf(w, `{"total_count": 6, "items": [{"name": "public-%[1]d", "visibility": "public"}, {"name": "private-%[1]d", "visibility": "private"}]}`, page)
+	}))
+	defer srv.Close()
+	s := NewScanner()
+	s.APIURL = srv.URL
+
+	tests := []struct {
+		max   int
+		want  []string
+		pages int
+	}{
+		{max: searchResultCap, want: []string{"public-1", "public-2", "public-3"}, pages: 3},
+		{max: 2, want: []string{"public-1", "public-2"}, pages: 2},
+	}
+	for _, tt := range tests {
+		s.SearchMax, pages = tt.max, nil
+
+		repos, err := s.searchRepositories(context.Background(), query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, repo := range repos {
+			names = append(names, repo.Name)
+		}
+		if fmt.Sprint(names) != fmt.Sprint(tt.want) {
+			t.Errorf("SearchMax %d: got %v, want %v", tt.max, names, tt.want)
+		}
+		if len(pages) != tt.pages {
+			t.Errorf("SearchMax %d: fetched pages %v, want %d of them", tt.max, pages, tt.pages)
+		}
+	}
+}
+
+func TestParseSearchTarget(t *testing.T) {
+	got, err := parseAccountInput("search:topic:documentation language:markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (target{kind: targetSearch, name: "topic:documentation language:markdown"}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if _, err := parseAccountInput("search:  "); err == nil {
+		t.Error("an empty search was accepted")
+	}
+}
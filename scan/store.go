@@ -0,0 +1,98 @@
+package scan
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/offftherecord/gitwiki/internal/atomicfile"
+)
+
+// repoState is what's remembered about a repository between runs
+type repoState struct {
+	PushedAt time.Time `json:"pushed_at"`
+	Result   string    `json:"result"`
+}
+
+// Store keeps the state of checked repositories between runs in a JSON file
+type Store struct {
+	path          string
+	skipUnchanged bool
+
+	Repos map[string]repoState `json:"repos"`
+}
+
+// Loads the store at path, starting empty if it doesn't exist yet
+func LoadStore(path string, skipUnchanged bool) (*Store, error) {
+	s := &Store{path: path, skipUnchanged: skipUnchanged, Repos: make(map[string]repoState)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Repos == nil {
+		s.Repos = make(map[string]repoState)
+	}
+
+	return s, nil
+}
+
+// Whether a repository can be skipped because it hasn't been pushed to since it was last checked
+func (s *Store) Unchanged(repo Repository) bool {
+	if s == nil || !s.skipUnchanged || repo.PushedAt.IsZero() {
+		return false
+	}
+
+	state, ok := s.Repos[repo.URL]
+	if !ok {
+		return false
+	}
+
+	// A result that didn't get an answer, or didn't decide anything, is probed again whatever the push date says
+	result, known := ParseProbeResult(state.Result)
+	if !known || retryable(Probe{Result: result}) || result == ProbeUnexpected {
+		return false
+	}
+
+	return !repo.PushedAt.After(state.PushedAt)
+}
+
+// Remembers the result of checking a repository
+func (s *Store) Record(repo Repository, result ProbeResult) {
+	if s == nil {
+		return
+	}
+
+	s.Repos[repo.URL] = repoState{PushedAt: repo.PushedAt, Result: result.String()}
+}
+
+// Writes the store back to its file
+func (s *Store) Save() error {
+	if s == nil {
+		return nil
+	}
+
+	f, err := atomicfile.Create(s.path)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		f.File.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,94 @@
+package scan
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIncrementalSkipsUnchangedRepositories(t *testing.T) {
+	pushed := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	repos := []Repository{
+		{Name: "stable", URL: "/acme/stable", HasWiki: true, PushedAt: pushed},
+		{Name: "busy", URL: "/acme/busy", HasWiki: true, PushedAt: pushed},
+	}
+
+	var mu sync.Mutex
+	probed := map[string]int{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/acme/repos", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		listed := append([]Repository(nil), repos...)
+		mu.Unlock()
+		listing(listed...)(w, r)
+	})
+	for _, name := range []string{"stable", "busy"} {
+		name := name
+		mux.HandleFunc("/acme/"+name+"/wiki", func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			probed[name]++
+			mu.Unlock()
+			w.Write([]byte(populatedWikiBody))
+		})
+		mux.Handle("/acme/"+name+"/wiki/", http.RedirectHandler("/login", http.StatusFound))
+	}
+	s, _ := fakeGitHub(t, mux)
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	scan := func() {
+		t.Helper()
+		store, err := LoadStore(path, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		run := s.NewRun()
+		run.Store = store
+		if err := run.Scan(context.Background(), "acme", &findingCollector{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scan()
+	if probed["stable"] != 1 || probed["busy"] != 1 {
+		t.Fatalf("first run probed %v, want every repository once", probed)
+	}
+
+	mu.Lock()
+	repos[1].PushedAt = pushed.Add(time.Hour)
+	mu.Unlock()
+	scan()
+	if probed["stable"] != 1 {
+		t.Errorf("second run probed the unchanged repository again")
+	}
+	if probed["busy"] != 2 {
+		t.Errorf("second run didn't probe the repository that was pushed to")
+	}
+}
+
+func TestUnchangedReprobesUndecidedResults(t *testing.T) {
+	pushed := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	store, err := LoadStore(filepath.Join(t.TempDir(), "state.json"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		result ProbeResult
+		want   bool
+	}{
+		{result: ProbeWriteable, want: true},
+		{result: ProbeRequiresAuth, want: true},
+		{result: ProbeError, want: false},
+		{result: ProbeThrottled, want: false},
+		{result: ProbeUnexpected, want: false},
+	} {
+		repo := Repository{Name: tt.result.String(), URL: "/acme/" + tt.result.String(), HasWiki: true, PushedAt: pushed}
+		store.Record(repo, tt.result)
+		if got := store.Unchanged(repo); got != tt.want {
+			t.Errorf("unchanged with a stored %s result = %t, want %t", tt.result, got, tt.want)
+		}
+	}
+}
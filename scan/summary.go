@@ -0,0 +1,120 @@
+package scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Summary counts how every repository in a run was classified
+type Summary struct {
+	Repositories int
+	Results      map[ProbeResult]int
+	// Vulnerable wikis per host
+	Hosts map[string]int
+	// Writeable wikis that could only be written to signed in
+	SignedInOnly int
+	// Accounts that were only partly scanned before AccountTimeout cut them off, or the scan was interrupted
+	Partial []string
+}
+
+func NewSummary() *Summary {
+	return &Summary{Results: make(map[ProbeResult]int), Hosts: make(map[string]int)}
+}
+
+// Counts a classified repository, and the host of its wiki when it's vulnerable
+func (s *Summary) Add(p Probe) {
+	s.Repositories++
+	s.Results[p.Result]++
+	if p.SignedInOnly {
+		s.SignedInOnly++
+	}
+
+	if p.Result.Vulnerable() {
+		if u, err := url.Parse(p.URL); err == nil {
+			s.Hosts[u.Host]++
+		}
+	}
+}
+
+// hostCount is a host with how many vulnerable wikis it had
+type hostCount struct {
+	Host       string `json:"host"`
+	Vulnerable int    `json:"vulnerable"`
+}
+
+// Gets the hosts with vulnerable wikis, most vulnerable first
+func (s *Summary) vulnerableHosts() []hostCount {
+	hosts := make([]hostCount, 0, len(s.Hosts))
+	for host, n := range s.Hosts {
+		hosts = append(hosts, hostCount{Host: host, Vulnerable: n})
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		if hosts[i].Vulnerable != hosts[j].Vulnerable {
+			return hosts[i].Vulnerable > hosts[j].Vulnerable
+		}
+		return hosts[i].Host < hosts[j].Host
+	})
+
+	return hosts
+}
+
+// Writes the hosts with vulnerable wikis, as a JSON array or as a line per host
+func (s *Summary) WriteHosts(w io.Writer, asJSON bool) error {
+	hosts := s.vulnerableHosts()
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(hosts)
+	}
+
+	for _, h := range hosts {
+		if _, err := fmt.Fprintf(w, "%s: %d\n", h.Host, h.Vulnerable); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Counts the repositories with any of the results
+func (s *Summary) count(results ...ProbeResult) int {
+	n := 0
+	for _, r := range results {
+		n += s.Results[r]
+	}
+
+	return n
+}
+
+// Writes the summary, grouping results by why a repository was or wasn't flagged
+func (s *Summary) Write(w io.Writer) {
+	fmt.Fprintf(w, "Repositories: %d\n", s.Repositories)
+	fmt.Fprintf(w, "With wikis: %d\n", s.Repositories-s.count(ProbeNoWiki))
+	fmt.Fprintf(w, "No wiki: %d\n", s.count(ProbeNoWiki))
+	fmt.Fprintf(w, "Wikis disabled by the account: %d\n", s.count(ProbeOrgDisabled))
+	fmt.Fprintf(w, "Wikis disabled: %d\n", s.count(ProbeDisabled))
+	if n := s.count(ProbeAdminDisabled); n > 0 {
+		fmt.Fprintf(w, "Wikis turned off by an organization or enterprise: %d\n", n)
+	}
+	fmt.Fprintf(w, "Access controlled: %d\n", s.count(ProbeRequiresAuth, ProbeSoftNotFound, ProbeReadOnly))
+	if n := s.count(ProbeReadable); n > 0 {
+		fmt.Fprintf(w, "Readable: %d\n", n)
+	}
+	vulnerable := fmt.Sprintf("Vulnerable: %d (first page: %d, writeable: %d", s.count(ProbeEmpty, ProbeWriteable, ProbeRuleMatch), s.count(ProbeEmpty), s.count(ProbeWriteable))
+	if n := s.count(ProbeRuleMatch); n > 0 {
+		vulnerable += fmt.Sprintf(", rules: %d", n)
+	}
+	fmt.Fprintln(w, vulnerable+")")
+	if s.SignedInOnly > 0 {
+		fmt.Fprintf(w, "Writeable only when signed in: %d\n", s.SignedInOnly)
+	}
+	fmt.Fprintf(w, "Throttled: %d\n", s.count(ProbeThrottled))
+	fmt.Fprintf(w, "Errors: %d\n", s.count(ProbeError, ProbeUnexpected, ProbeInvalidURL))
+	if len(s.Partial) > 0 {
+		fmt.Fprintf(w, "Partly scanned accounts: %s\n", strings.Join(s.Partial, ", "))
+	}
+}
@@ -0,0 +1,86 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOrgDisabledCounts(t *testing.T) {
+	mux := http.NewServeMux()
+	repos := []Repository{{Name: "nowiki", URL: "/acme/nowiki"}}
+	for i := 1; i <= 5; i++ {
+		name := fmt.Sprintf("r%d", i)
+		repos = append(repos, Repository{Name: name, URL: "/acme/" + name, HasWiki: true})
+		mux.Handle("/acme/"+name+"/wiki", http.RedirectHandler("/acme", http.StatusFound))
+	}
+	mux.HandleFunc("/users/acme/repos", listing(repos...))
+	s, _ := fakeGitHub(t, mux)
+
+	for _, tt := range []struct {
+		skip                  bool
+		disabled, orgDisabled int
+	}{
+		{skip: false, disabled: 5},
+		{skip: true, disabled: orgDisabledThreshold, orgDisabled: 5 - orgDisabledThreshold},
+	} {
+		s.Concurrency = 1
+		s.SkipOrgDisabled = tt.skip
+		run := s.NewRun()
+		if err := run.Scan(context.Background(), "acme", &findingCollector{}); err != nil {
+			t.Fatal(err)
+		}
+
+		var out strings.Builder
+		run.Summary.Write(&out)
+		for _, want := range []string{
+			"Repositories: 6\n",
+			"No wiki: 1\n",
+			fmt.Sprintf("Wikis disabled by the account: %d\n", tt.orgDisabled),
+			fmt.Sprintf("Wikis disabled: %d\n", tt.disabled),
+		} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("with SkipOrgDisabled %t the summary is missing %q:\n%s", tt.skip, want, out.String())
+			}
+		}
+	}
+}
+
+func TestVulnerableHosts(t *testing.T) {
+	s := NewSummary()
+	for _, p := range []Probe{
+		{Result: ProbeWriteable, URL: "https://github.com/a/one/wiki"},
+		{Result: ProbeEmpty, URL: "https://github.com/a/two/wiki"},
+		{Result: ProbeWriteable, URL: "https://ghe.example.com/b/one/wiki"},
+		{Result: ProbeEmpty, URL: "https://git.example.org/c/one/wiki"},
+		{Result: ProbeWriteable, URL: "https://git.example.org/c/two/wiki"},
+		{Result: ProbeRequiresAuth, URL: "https://ghe.example.com/b/two/wiki"},
+		{Result: ProbeSoftNotFound, URL: "https://other.example.net/d/one/wiki"},
+		{Result: ProbeNoWiki, URL: "https://github.com/a/three"},
+	} {
+		s.Add(p)
+	}
+
+	var text strings.Builder
+	if err := s.WriteHosts(&text, false); err != nil {
+		t.Fatal(err)
+	}
+	if want := "git.example.org: 2\ngithub.com: 2\nghe.example.com: 1\n"; text.String() != want {
+		t.Errorf("hosts =\n%s\nwant\n%s", text.String(), want)
+	}
+
+	var asJSON strings.Builder
+	if err := s.WriteHosts(&asJSON, true); err != nil {
+		t.Fatal(err)
+	}
+	var hosts []hostCount
+	if err := json.Unmarshal([]byte(asJSON.String()), &hosts); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(hosts) != "[{git.example.org 2} {github.com 2} {ghe.example.com 1}]" {
+		t.Errorf("JSON hosts = %v, want the same counts as the text output", hosts)
+	}
+}
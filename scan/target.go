@@ -0,0 +1,215 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Kinds of scan targets
+const (
+	targetAccount = "account"
+	targetOrg     = "org"
+	targetUser    = "user"
+	targetProject = "project"
+	targetSearch  = "search"
+	targetURL     = "url"
+	targetRepo    = "repo"
+)
+
+// target is something to scan, parsed from an input line
+type target struct {
+	kind string
+	// The account, the organization owning the project, the search query, the repository URL, or owner/repo
+	name string
+	// The project number, for project targets
+	number int
+}
+
+// Parses an input line into a target
+//
+// Plain names are accounts, "org:name" and "user:name" are accounts listed as that type, "project:org/number"
+// is an organization project, and "search:query" is every repository on GitHub matching a repository search.
+// "url:https://host/owner/repo/wiki" probes that one wiki directly without using the API, so it works for any
+// GitHub-like host. "repo:owner/repo", or "repo:" and the repository's URL, looks up just that repository.
+func parseAccountInput(input string) (target, error) {
+	if rawURL, ok := strings.CutPrefix(input, "url:"); ok {
+		repoURL, err := parseWikiURL(rawURL)
+		if err != nil {
+			return target{}, err
+		}
+
+		return target{kind: targetURL, name: repoURL}, nil
+	}
+
+	if repo, ok := strings.CutPrefix(input, "repo:"); ok {
+		fullName, err := parseRepoName(repo)
+		if err != nil {
+			return target{}, err
+		}
+
+		return target{kind: targetRepo, name: fullName}, nil
+	}
+
+	if query, ok := strings.CutPrefix(input, "search:"); ok {
+		if strings.TrimSpace(query) == "" {
+			return target{}, fmt.Errorf("search query cannot be empty in %q", input)
+		}
+
+		return target{kind: targetSearch, name: query}, nil
+	}
+
+	if rest, ok := strings.CutPrefix(input, "project:"); ok {
+		org, number, ok := strings.Cut(rest, "/")
+		n, err := strconv.Atoi(number)
+		if !ok || org == "" || err != nil || n <= 0 {
+			return target{}, fmt.Errorf("project must be in the form project:org/number, got %q", input)
+		}
+
+		return target{kind: targetProject, name: org, number: n}, nil
+	}
+
+	for _, kind := range []string{targetOrg, targetUser} {
+		if name, ok := strings.CutPrefix(input, kind+":"); ok {
+			if name == "" {
+				return target{}, fmt.Errorf("account name cannot be empty in %q", input)
+			}
+
+			return target{kind: kind, name: name}, nil
+		}
+	}
+
+	return target{kind: targetAccount, name: input}, nil
+}
+
+// Gets the repositories to scan for a target
+func (t target) repositories(ctx context.Context, s *Scanner) ([]Repository, error) {
+	switch t.kind {
+	case targetProject:
+		return s.getProjectRepositories(ctx, t.name, t.number)
+	case targetSearch:
+		return s.searchRepositories(ctx, t.name)
+	case targetURL:
+		return []Repository{repositoryFromURL(t.name)}, nil
+	case targetRepo:
+		repo, err := s.getRepository(ctx, t.name)
+		if err != nil {
+			return nil, err
+		}
+		return []Repository{repo}, nil
+	case targetOrg, targetUser:
+		if len(s.OnlyRepos) > 0 {
+			return s.getNamedRepositories(ctx, t.name, s.OnlyRepos)
+		}
+		return s.getTypedAccountRepositories(ctx, t.kind, t.name)
+	default:
+		if len(s.OnlyRepos) > 0 {
+			return s.getNamedRepositories(ctx, t.name, s.OnlyRepos)
+		}
+		return s.getRepositories(ctx, t.name)
+	}
+}
+
+// Gets just the named repositories of an account, one API call each, with bare names taken as the account's
+//
+// Repositories that aren't found are skipped with a warning, the way a listing wouldn't have had them.
+func (s *Scanner) getNamedRepositories(ctx context.Context, account string, names []string) ([]Repository, error) {
+	var repos []Repository
+	for _, name := range names {
+		fullName := name
+		if !strings.Contains(name, "/") {
+			fullName = account + "/" + name
+		}
+
+		repo, err := s.getRepository(ctx, fullName)
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+			s.logError("warn", "fetch repository", account, name, err)
+			continue
+		} else if err != nil {
+			return listingFailed(repos, err)
+		}
+		if s.scannable(repo) {
+			repos = append(repos, repo)
+		}
+	}
+
+	return repos, nil
+}
+
+// Parses a wiki or repository URL into the repository URL checkWiki works from
+func parseWikiURL(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", fmt.Errorf("invalid wiki URL %q: %w", rawURL, err)
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", fmt.Errorf("wiki URL must be an absolute http(s) URL, got %q", rawURL)
+	}
+
+	u.RawQuery = ""
+	u.Fragment = ""
+	u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/wiki")
+	if strings.Trim(u.Path, "/") == "" {
+		return "", fmt.Errorf("wiki URL has no repository path, got %q", rawURL)
+	}
+
+	return u.String(), nil
+}
+
+// Parses owner/repo, or a repository URL, into owner/repo
+func parseRepoName(value string) (string, error) {
+	path := strings.TrimSpace(value)
+	if strings.Contains(path, "://") {
+		u, err := url.Parse(path)
+		if err != nil {
+			return "", fmt.Errorf("invalid repository URL %q: %w", value, err)
+		}
+		path = u.Path
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("repository must be owner/repo or its URL, got %q", value)
+	}
+
+	return parts[0] + "/" + strings.TrimSuffix(parts[1], ".git"), nil
+}
+
+// Builds a repository from its URL, named owner/repo from the path and assumed to have a wiki
+func repositoryFromURL(repoURL string) Repository {
+	name := repoURL
+	if u, err := url.Parse(repoURL); err == nil {
+		name = strings.Trim(u.Path, "/")
+	}
+
+	return Repository{Name: name, URL: repoURL, HasWiki: true}
+}
+
+// partialListingError is a listing that failed partway, after some repositories were already listed
+type partialListingError struct {
+	err    error
+	listed int
+}
+
+func (e *partialListingError) Error() string {
+	return fmt.Sprintf("listing incomplete after %d repositories: %v", e.listed, e.err)
+}
+
+func (e *partialListingError) Unwrap() error {
+	return e.err
+}
+
+// Fails a listing, keeping what was listed so far unless there's nothing or we were rate limited
+func listingFailed(repos []Repository, err error) ([]Repository, error) {
+	var httpErr *HTTPError
+	if len(repos) == 0 || (errors.As(err, &httpErr) && httpErr.RateLimited) {
+		return nil, err
+	}
+
+	return repos, &partialListingError{err: err, listed: len(repos)}
+}
@@ -0,0 +1,120 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestParseProjectTarget(t *testing.T) {
+	got, err := parseAccountInput("project:acme/12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (target{kind: targetProject, name: "acme", number: 12}); got != want {
+		t.Errorf("parseAccountInput(project:acme/12) = %+v, want %+v", got, want)
+	}
+
+	for _, input := range []string{"project:acme", "project:/12", "project:acme/0", "project:acme/-1", "project:acme/twelve"} {
+		if _, err := parseAccountInput(input); err == nil {
+			t.Errorf("parseAccountInput(%q) succeeded", input)
+		}
+	}
+}
+
+func TestPartialListings(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/acme/repos", func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		page = max(page, 1)
+		if page == 3 {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s/users/acme/repos?page=%d>; rel="next"`, r.Host, page+1))
+		name := fmt.Sprintf("repo%d", page)
+		listing(Repository{Name: name, URL: "/acme/" + name, HasWiki: true})(w, r)
+	})
+	for _, name := range []string{"repo1", "repo2"} {
+		mux.HandleFunc("/acme/"+name+"/wiki", respond(http.StatusOK, populatedWikiBody))
+		mux.Handle("/acme/"+name+"/wiki/", http.RedirectHandler("/login", http.StatusFound))
+	}
+	s, _ := fakeGitHub(t, mux)
+
+	repos, err := s.getRepositories(context.Background(), "acme")
+	var partial *partialListingError
+	if !errors.As(err, &partial) {
+		t.Fatalf("listing that failed on page 3 = %v, want a partial listing", err)
+	}
+	if len(repos) != 2 || repos[0].Name != "repo1" || repos[1].Name != "repo2" {
+		t.Errorf("partial listing kept %+v, want pages 1 and 2", repos)
+	}
+
+	for _, allowPartial := range []bool{true, false} {
+		var c findingCollector
+		s.AllowPartial = allowPartial
+		run := s.NewRun()
+		err := run.Scan(context.Background(), "acme", &c)
+
+		if allowPartial {
+			if err != nil || len(c.findings) != 2 {
+				t.Errorf("AllowPartial scanned %d repositories (%v), want the 2 that were listed", len(c.findings), err)
+			}
+			if coverage := run.Manifest.Accounts[0]; !coverage.Partial {
+				t.Errorf("AllowPartial coverage = %+v, want it marked partial", coverage)
+			}
+		} else if err == nil || len(c.findings) != 0 {
+			t.Errorf("scan of a failed listing probed %d repositories (%v), want none and the listing's error", len(c.findings), err)
+		}
+	}
+}
+
+func TestParseURLTarget(t *testing.T) {
+	tests := map[string]string{
+		"url:https://git.example.com/acme/docs/wiki":         "https://git.example.com/acme/docs",
+		"url:https://git.example.com/acme/docs/wiki/":        "https://git.example.com/acme/docs",
+		"url:https://git.example.com/acme/docs":              "https://git.example.com/acme/docs",
+		"url: http://localhost:8080/acme/docs/wiki?x=1#frag": "http://localhost:8080/acme/docs",
+	}
+	for input, want := range tests {
+		got, err := parseAccountInput(input)
+		if err != nil {
+			t.Errorf("parseAccountInput(%q): %v", input, err)
+			continue
+		}
+		if got.kind != targetURL || got.name != want {
+			t.Errorf("parseAccountInput(%q) = %+v, want a url target for %s", input, got, want)
+		}
+	}
+
+	for _, input := range []string{"url:", "url:git.example.com/acme/docs", "url:ftp://git.example.com/acme/docs", "url:https://git.example.com/wiki", "url:https:///acme/docs"} {
+		if _, err := parseAccountInput(input); err == nil {
+			t.Errorf("parseAccountInput(%q) succeeded", input)
+		}
+	}
+}
+
+func TestScanURLTargetWithoutAPI(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/acme/docs/wiki", respond(http.StatusOK, populatedWikiBody))
+	mux.HandleFunc("/acme/docs/wiki/", respond(http.StatusOK, populatedWikiBody))
+	s, srv := fakeGitHub(t, mux)
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("url: target called the API: %s", r.URL)
+	}))
+	defer api.Close()
+	s.APIURL = api.URL
+
+	findings, err := s.ScanAccount(context.Background(), "url:"+srv.URL+"/acme/docs/wiki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 || findings[0].Result != ProbeWriteable || findings[0].URL != srv.URL+"/acme/docs/wiki" || findings[0].Repo != "acme/docs" {
+		t.Errorf("got findings %+v, want acme/docs as writeable", findings)
+	}
+}
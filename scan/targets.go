@@ -0,0 +1,78 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Reads newline separated targets, skipping blank lines and # comments
+func ParseTargets(r io.Reader) ([]string, error) {
+	var targets []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		targets = append(targets, line)
+	}
+
+	return targets, scanner.Err()
+}
+
+// Gets the targets listed in a file in a GitHub repository, given as owner/repo:path
+func (s *Scanner) TargetsFromRepo(ctx context.Context, spec string) ([]string, error) {
+	repo, path, ok := strings.Cut(spec, ":")
+	owner, name, slash := strings.Cut(repo, "/")
+	if !ok || !slash || owner == "" || name == "" || path == "" {
+		return nil, fmt.Errorf("targets repo must be in the form owner/repo:path, got %q", spec)
+	}
+
+	escaped := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range escaped {
+		escaped[i] = url.PathEscape(segment)
+	}
+	u := fmt.Sprintf("%s/repos/%s/%s/contents/%s", s.APIURL, url.PathEscape(owner), url.PathEscape(name), strings.Join(escaped, "/"))
+
+	req, err := newAPIRequest(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.getAPIClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewHTTPError("fetch targets file", resp)
+	}
+
+	var file struct {
+		Type     string `json:"type"`
+		Encoding string `json:"encoding"`
+		Content  string `json:"content"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&file); err != nil {
+		return nil, err
+	}
+	if file.Type != "file" || file.Encoding != "base64" {
+		return nil, fmt.Errorf("%s is not a file the contents API can return (type %q, encoding %q)", spec, file.Type, file.Encoding)
+	}
+
+	// The content is wrapped with newlines, which the decoder doesn't accept
+	content, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(file.Content, "\n", ""))
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", spec, err)
+	}
+
+	return ParseTargets(strings.NewReader(string(content)))
+}
@@ -0,0 +1,55 @@
+package scan
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseTargets(t *testing.T) {
+	input := "# nightly targets\nacme\n\n   \norg:globex\n  user:initech  \n# user:skipped\n\tsearch:topic:docs \n"
+	got, err := ParseTargets(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"acme", "org:globex", "user:initech", "search:topic:docs"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("ParseTargets = %q, want %q", got, want)
+	}
+}
+
+func TestTargetsFromRepo(t *testing.T) {
+	content := base64.StdEncoding.EncodeToString([]byte("# scanned nightly\nacme\n\norg:globex\nuser:initech\n"))
+	// The contents API wraps its base64 at 60 columns
+	var wrapped strings.Builder
+	for len(content) > 60 {
+		wrapped.WriteString(content[:60] + "\n")
+		content = content[60:]
+	}
+	wrapped.WriteString(content + "\n")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/acme/config/contents/scan/targets.txt", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"type": "file", "encoding": "base64", "content": wrapped.String()})
+	})
+	mux.HandleFunc("/repos/acme/config/contents/scan", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]string{{"type": "file", "name": "targets.txt"}})
+	})
+	s, _ := fakeGitHub(t, mux)
+
+	got, err := s.TargetsFromRepo(context.Background(), "acme/config:scan/targets.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"acme", "org:globex", "user:initech"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("TargetsFromRepo = %q, want %q", got, want)
+	}
+
+	for _, spec := range []string{"acme/config:scan", "acme/config:missing.txt", "acme/config", "acme:targets.txt"} {
+		if _, err := s.TargetsFromRepo(context.Background(), spec); err == nil {
+			t.Errorf("TargetsFromRepo(%q) succeeded", spec)
+		}
+	}
+}
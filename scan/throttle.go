@@ -0,0 +1,57 @@
+package scan
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Bounds for how long probes back off after being throttled
+const (
+	throttleBase = 5 * time.Second
+	throttleMax  = 5 * time.Minute
+)
+
+// probeThrottle holds back all probes for a while after the wiki host answers with a 429
+type probeThrottle struct {
+	mu      sync.Mutex
+	until   time.Time
+	strikes int
+}
+
+// Waits until probes are allowed again, or the context is done
+func (t *probeThrottle) wait(ctx context.Context) error {
+	t.mu.Lock()
+	wait := time.Until(t.until)
+	t.mu.Unlock()
+
+	return sleepContext(ctx, wait)
+}
+
+// Updates the throttle from a probe's response, backing off further with each 429 in a row, and gets how long
+// probes are held back for when that pushed the wait further out
+func (t *probeThrottle) observe(resp *http.Response, jitter Jitter) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.strikes = 0
+		return 0, false
+	}
+
+	t.strikes++
+	wait := jitter.Backoff(t.strikes, throttleBase, throttleMax)
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		wait = max(wait, time.Duration(seconds)*time.Second)
+	}
+
+	until := time.Now().Add(wait)
+	if !until.After(t.until) {
+		return wait, false
+	}
+	t.until = until
+
+	return wait, true
+}
@@ -0,0 +1,100 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// tokenInfo is who a token belongs to and what it can do
+type tokenInfo struct {
+	Login     string
+	Remaining int
+	Limit     int
+	// The classic token scopes, or nil for fine-grained tokens and apps that don't have any
+	Scopes []string
+}
+
+// Looks up the user a token authenticates as
+func (s *Scanner) checkToken(ctx context.Context, token string) (tokenInfo, error) {
+	req, err := newAPIRequest(ctx, http.MethodGet, s.APIURL+"/user", nil)
+	if err != nil {
+		return tokenInfo{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	// The token pool would swap in a token of its own, so this goes straight out
+	client := s.NewClient()
+	client.Transport = s.apiTransport()
+	resp, err := client.Do(req)
+	if err != nil {
+		return tokenInfo{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return tokenInfo{}, NewHTTPError("check token", resp)
+	}
+
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return tokenInfo{}, err
+	}
+
+	info := tokenInfo{Login: user.Login}
+	info.Remaining, _ = strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	info.Limit, _ = strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	if scopes, ok := resp.Header["X-Oauth-Scopes"]; ok {
+		info.Scopes = []string{}
+		for _, scope := range strings.Split(strings.Join(scopes, ","), ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				info.Scopes = append(info.Scopes, scope)
+			}
+		}
+	}
+
+	return info, nil
+}
+
+// Checks every token before the scan, so a bad one fails straight away rather than as 401s partway through
+//
+// Tokens GitHub turns down are an error. Anything else that goes wrong is only a warning, so a scan can still
+// go ahead when the check itself can't be made.
+func (s *Scanner) CheckTokens(ctx context.Context) error {
+	values := s.tokens.values()
+	for i, token := range values {
+		info, err := s.checkToken(ctx, token)
+		var httpErr *HTTPError
+		switch {
+		case errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusUnauthorized:
+			return fmt.Errorf("token %d of %d is invalid or expired", i+1, len(values))
+		case err != nil:
+			s.logError("warn", "check token", "", "", err)
+			continue
+		}
+
+		s.infof("Token %d of %d authenticates as %s, with %d of %d API requests left", i+1, len(values), info.Login, info.Remaining, info.Limit)
+		if s.includesPrivate() && info.Scopes != nil && !hasScope(info.Scopes, "repo") {
+			s.warnf("Token %d of %d doesn't have the repo scope, so private repositories won't be seen with it", i+1, len(values))
+		}
+	}
+
+	return nil
+}
+
+// Whether a token's scopes include scope
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
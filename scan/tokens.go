@@ -0,0 +1,306 @@
+package scan
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateState is what's left of one rate limit, as of the last response
+type rateState struct {
+	remaining int
+	reset     time.Time
+}
+
+// apiToken is a GitHub token, or "" for unauthenticated requests, with its rate limits by resource
+type apiToken struct {
+	value  string
+	limits map[string]rateState
+	// Whether it's a GitHub App installation token, which doesn't belong to a user
+	installation bool
+}
+
+// Whether the token has requests left for a resource, counting a limit that's been reset as fresh
+func (t *apiToken) available(resource string, now time.Time) bool {
+	state, ok := t.limits[resource]
+	return !ok || state.remaining > 0 || !now.Before(state.reset)
+}
+
+// tokenPool hands out GitHub tokens, moving on to the next one when a token's rate limit runs out
+type tokenPool struct {
+	mu      sync.Mutex
+	tokens  []*apiToken
+	current int
+}
+
+// Adds tokens to the pool, skipping blanks and ones it already has
+func (p *tokenPool) add(values ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || p.has(value) {
+			continue
+		}
+		p.tokens = append(p.tokens, &apiToken{value: value, limits: make(map[string]rateState)})
+	}
+}
+
+// Adds a token ahead of the others, so it's the primary one and the first to be used
+func (p *tokenPool) addFirst(value string, installation bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.tokens = append([]*apiToken{{value: value, limits: make(map[string]rateState), installation: installation}}, p.tokens...)
+}
+
+// Swaps a token for a new one with the same rate limits, as when an installation token is refreshed
+//
+// Requests already using the old token carry on with it, as it stays valid until it expires.
+func (p *tokenPool) replace(old, value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, t := range p.tokens {
+		if t.value == old {
+			p.tokens[i] = &apiToken{value: value, limits: t.limits, installation: t.installation}
+			return
+		}
+	}
+}
+
+func (p *tokenPool) has(value string) bool {
+	for _, t := range p.tokens {
+		if t.value == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Gets the first token, for things that can only use one, or "" without any
+func (p *tokenPool) primary() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.tokens) == 0 {
+		return ""
+	}
+	return p.tokens[0].value
+}
+
+// Gets every user's token, in the order they were added, leaving out installation tokens
+func (p *tokenPool) values() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var values []string
+	for _, t := range p.tokens {
+		if t.value != "" && !t.installation {
+			values = append(values, t.value)
+		}
+	}
+
+	return values
+}
+
+// Gets a token with requests left for a resource, or how long until one of them has some again
+//
+// Without any tokens, requests go out unauthenticated and are tracked on their own rate limit.
+func (p *tokenPool) pick(resource string) (*apiToken, time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.tokens) == 0 {
+		p.tokens = []*apiToken{{limits: make(map[string]rateState)}}
+	}
+
+	now := time.Now()
+	var soonest time.Time
+	for i := range p.tokens {
+		t := p.tokens[(p.current+i)%len(p.tokens)]
+		if t.available(resource, now) {
+			p.current = (p.current + i) % len(p.tokens)
+			return t, 0
+		}
+		if reset := t.limits[resource].reset; soonest.IsZero() || reset.Before(soonest) {
+			soonest = reset
+		}
+	}
+
+	return nil, time.Until(soonest)
+}
+
+// Records a token's rate limit from a response, returning whether it's now used up
+func (p *tokenPool) observe(t *apiToken, resp *http.Response) bool {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return false
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return false
+	}
+
+	resource := resp.Header.Get("X-RateLimit-Resource")
+	if resource == "" {
+		resource = apiResource(resp.Request)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t.limits[resource] = rateState{remaining: remaining, reset: time.Unix(reset, 0)}
+
+	return remaining == 0
+}
+
+// Gets the rate limit resource a request counts against, which the response confirms
+func apiResource(req *http.Request) string {
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/graphql"):
+		return "graphql"
+	case strings.Contains(req.URL.Path, "/search/"):
+		return "search"
+	default:
+		return "core"
+	}
+}
+
+// tokenTransport authenticates API requests with a token from the pool, switching tokens when one runs out and
+// only waiting for a reset once they all have
+type tokenTransport struct {
+	base http.RoundTripper
+	s    *Scanner
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resource := apiResource(req)
+	secondary := 0
+	for {
+		token, wait := t.s.tokens.pick(resource)
+		if token == nil {
+			t.s.warnf("Rate limit reached on every token, waiting %s", wait.Round(time.Second))
+			if err := sleepContext(req.Context(), wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// RoundTrippers must not modify the caller's request, and a retry needs the body again
+		r := req.Clone(req.Context())
+		if req.Body != nil && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r.Body = body
+		}
+		if token.value != "" {
+			r.Header.Set("Authorization", "Bearer "+token.value)
+		}
+
+		resp, err := t.base.RoundTrip(r)
+		if err != nil {
+			t.s.Metrics.httpError("api")
+			t.s.debugf("API request failed: %v", err)
+			return nil, err
+		}
+		if resp.StatusCode >= 500 {
+			t.s.Metrics.httpError("api")
+		}
+		t.s.debugf("API %s %s: %s", req.Method, req.URL, resp.Status)
+
+		// A request turned away because the token ran out can go again with another one
+		usedUp := t.s.tokens.observe(token, resp)
+		if usedUp && NewHTTPError("", resp).RateLimited && (req.Body == nil || req.GetBody != nil) {
+			drainAndClose(resp)
+			continue
+		}
+
+		// Secondary rate limits aren't in the headers' counts, and hitting them again straight away gets the
+		// token blocked for longer, so wait out the Retry-After before trying the same request again
+		if wait, ok := secondaryRateLimit(resp, secondary+1, t.s.BackoffJitter); ok && secondary < maxSecondaryRetries &&
+			(req.Body == nil || req.GetBody != nil) {
+			drainAndClose(resp)
+			secondary++
+			t.s.warnf("Secondary rate limit reached, waiting %s", wait.Round(time.Second))
+			if err := sleepContext(req.Context(), wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		return resp, nil
+	}
+}
+
+// Times a request is tried again after hitting a secondary rate limit
+const maxSecondaryRetries = 3
+
+// Least time to wait out a secondary rate limit that doesn't say, as GitHub suggests, and the most the backoff
+// on top of it grows to
+const (
+	secondaryRateLimitWait    = time.Minute
+	maxSecondaryRateLimitWait = 4 * time.Minute
+)
+
+// Gets how long to wait when a response is a secondary rate limit: a 403 or 429 that isn't from running out of
+// the primary limit, with a Retry-After or GitHub's message about it
+//
+// Without a Retry-After, attempt (starting at 1) backs off on top of the minute GitHub asks for, randomized by
+// jitter.
+func secondaryRateLimit(resp *http.Response, attempt int, jitter Jitter) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		return max(time.Duration(seconds)*time.Second, time.Second), true
+	}
+
+	// Peek at the message, leaving the body for whoever gets the response
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxDrainBytes))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+	if bytes.Contains(bytes.ToLower(body), []byte("secondary rate limit")) {
+		return secondaryRateLimitWait + jitter.Backoff(attempt, secondaryRateLimitWait, maxSecondaryRateLimitWait), true
+	}
+
+	return 0, false
+}
+
+// Authenticates a wiki probe with the first token, so private and SSO protected wikis aren't missed
+//
+// Only the wiki host gets the token, never url: targets on other hosts or redirects away from it.
+func (s *Scanner) setProbeAuth(req *http.Request) {
+	if token := s.probeToken(req.URL); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// Gets the token a wiki probe to u is sent with, or "" when it goes out logged out
+func (s *Scanner) probeToken(u *url.URL) string {
+	token := s.tokens.primary()
+	if s.AnonymousProbes || token == "" {
+		return ""
+	}
+
+	host, err := url.Parse(s.ProbeHost)
+	if err != nil || !strings.EqualFold(u.Host, host.Host) || u.Scheme != host.Scheme {
+		return ""
+	}
+
+	return token
+}
@@ -0,0 +1,31 @@
+package scan
+
+import "context"
+
+// Tracer records spans for account scans and the probes within them
+type Tracer interface {
+	// Starts the root span for an account, returning a func that ends it with the number of repositories probed
+	StartAccount(ctx context.Context, account string) (context.Context, func(probed int, err error))
+	// Starts a child span for probing one repository, returning a func that ends it with the outcome
+	StartProbe(ctx context.Context, account string, repo Repository) (context.Context, func(p Probe, err error))
+}
+
+// Gets the tracer spans go to, one that doesn't record anything without Tracer
+func (s *Scanner) tracer() Tracer {
+	if s.Tracer != nil {
+		return s.Tracer
+	}
+
+	return noopTracer{}
+}
+
+// noopTracer doesn't record anything
+type noopTracer struct{}
+
+func (noopTracer) StartAccount(ctx context.Context, account string) (context.Context, func(int, error)) {
+	return ctx, func(int, error) {}
+}
+
+func (noopTracer) StartProbe(ctx context.Context, account string, repo Repository) (context.Context, func(Probe, error)) {
+	return ctx, func(Probe, error) {}
+}
@@ -0,0 +1,44 @@
+package scan
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Checks that a repository URL is an https URL on one of hosts with an owner/repo path
+func validateRepoURL(rawURL string, hosts []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case u.Scheme != "https":
+		return fmt.Errorf("scheme must be https, got %q", u.Scheme)
+	case u.Host == "":
+		return fmt.Errorf("host is empty")
+	case u.User != nil:
+		return fmt.Errorf("URL must not contain credentials")
+	case u.RawQuery != "" || u.Fragment != "":
+		return fmt.Errorf("URL must not have a query or fragment")
+	}
+
+	allowed := false
+	for _, host := range hosts {
+		if strings.EqualFold(u.Host, host) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("host %q isn't one of %s", u.Host, strings.Join(hosts, ", "))
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("path %q isn't an owner/repo path", u.Path)
+	}
+
+	return nil
+}
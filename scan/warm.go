@@ -0,0 +1,65 @@
+package scan
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Most of a response body to read so its connection can go back to the pool
+const maxDrainBytes = 64 << 10
+
+// Reads what's left of a response body before closing it, so the connection can be reused
+func drainAndClose(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+	resp.Body.Close()
+}
+
+// Keeps up to n idle connections per host, so a warmed pool isn't cut down to the default of 2
+//
+// Connections are only ever added to, so a run with more probes than an earlier one gets what it needs.
+func (s *Scanner) setIdleConnsPerHost(n int) {
+	s.idleMu.Lock()
+	defer s.idleMu.Unlock()
+
+	if n > s.transport.MaxIdleConnsPerHost {
+		s.transport.MaxIdleConnsPerHost = n
+	}
+	if s.transport.MaxIdleConns != 0 && n > s.transport.MaxIdleConns {
+		s.transport.MaxIdleConns = n
+	}
+}
+
+// Opens n keep-alive connections to ProbeHost at once, so the scan starts with them ready in the pool, giving up
+// when the context is done, and gets how many were opened
+func (s *Scanner) WarmConnections(ctx context.Context, n int) int {
+	s.setIdleConnsPerHost(n)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	warmed := 0
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.ProbeHost+"/", nil)
+			if err != nil {
+				return
+			}
+			resp, err := s.NewClient().Do(req)
+			if err != nil {
+				return
+			}
+			drainAndClose(resp)
+
+			mu.Lock()
+			warmed++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return warmed
+}
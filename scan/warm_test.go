@@ -0,0 +1,132 @@
+package scan
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWarmConnectionsAreReused(t *testing.T) {
+	var opened atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Holds the warming requests open together, so each one needs its own connection
+		if r.Method == http.MethodHead {
+			time.Sleep(50 * time.Millisecond)
+		}
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			opened.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	s := writeableOnly(srv)
+	if warmed := s.WarmConnections(context.Background(), 4); warmed != 4 {
+		t.Fatalf("warmed %d connections, want 4", warmed)
+	}
+	if s.transport.MaxIdleConnsPerHost < 4 {
+		t.Errorf("transport keeps %d idle connections per host, want at least the 4 warmed", s.transport.MaxIdleConnsPerHost)
+	}
+
+	repo := Repository{Name: "o/r", URL: srv.URL + "/o/r", HasWiki: true}
+	for i := 0; i < 10; i++ {
+		if _, err := checkAll(s, repo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := opened.Load(); n != 4 {
+		t.Errorf("server accepted %d connections, want only the 4 warmed ones", n)
+	}
+}
+
+// Gets a scanner that only runs the writeable probe, with srv as its probe host
+func writeableOnly(srv *httptest.Server) *Scanner {
+	s := newTestScanner()
+	s.Stages = []Stage{{name: "writeable", run: stageWriteable}}
+	s.ProbeHost = srv.URL
+
+	return s
+}
+
+// Serves a wiki whose pages need a login over TLS
+func tlsWiki(b *testing.B) *httptest.Server {
+	b.Helper()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}))
+	b.Cleanup(srv.Close)
+
+	return srv
+}
+
+// A transport trusting srv's certificate, with no connections open yet
+func tlsTransport(srv *httptest.Server) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+
+	return t
+}
+
+// Probes on a new transport each time, paying for the TLS handshake on every one
+func BenchmarkProbeCold(b *testing.B) {
+	srv := tlsWiki(b)
+	s := writeableOnly(srv)
+	repo := Repository{Name: "o/r", URL: srv.URL + "/o/r", HasWiki: true}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.transport = tlsTransport(srv)
+		if _, err := checkAll(s, repo); err != nil {
+			b.Fatal(err)
+		}
+		s.transport.CloseIdleConnections()
+	}
+}
+
+// Probes on a transport WarmConnections already opened connections on, so none of them wait for a handshake
+func BenchmarkProbeWarm(b *testing.B) {
+	srv := tlsWiki(b)
+	s := writeableOnly(srv)
+	repo := Repository{Name: "o/r", URL: srv.URL + "/o/r", HasWiki: true}
+	s.transport = tlsTransport(srv)
+	defer s.transport.CloseIdleConnections()
+	if warmed := s.WarmConnections(context.Background(), 4); warmed != 4 {
+		b.Fatalf("warmed %d connections, want 4", warmed)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := checkAll(s, repo); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestWarmConnectionsStopWithTheContext(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	s := NewScanner()
+	s.ProbeHost = srv.URL
+	if warmed := s.WarmConnections(ctx, 3); warmed != 0 {
+		t.Errorf("warmed %d connections to a server that never answered", warmed)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("warming took %s after the context was done", elapsed)
+	}
+}
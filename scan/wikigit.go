@@ -0,0 +1,134 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// How long fetching a wiki's history may take
+const wikiGitTimeout = time.Minute
+
+// wikiCommit is the latest commit in a wiki's git repository
+type wikiCommit struct {
+	Author string
+	Date   time.Time
+}
+
+// Gets the latest commit of a repository's wiki from its .wiki.git repository
+//
+// This only fetches, with a shallow bare clone of the last commit and no file contents, and never pushes.
+func (s *Scanner) getLastWikiCommit(ctx context.Context, repoURL string) (wikiCommit, error) {
+	dir, err := os.MkdirTemp("", "gitwiki-")
+	if err != nil {
+		return wikiCommit{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(ctx, wikiGitTimeout)
+	defer cancel()
+
+	args := []string{"-c", "credential.helper=", "-c", "http.userAgent=" + s.UserAgent}
+	args = append(args, s.gitTLSArgs()...)
+	args = append(args, "clone", "--quiet", "--bare", "--depth=1", "--filter=blob:none", repoURL+".wiki.git", dir)
+	if err := runGit(ctx, s.gitAuthEnv(repoURL), args...); err != nil {
+		return wikiCommit{}, err
+	}
+
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "-C", dir, "log", "-1", "--format=%an%x00%aI")
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return wikiCommit{}, fmt.Errorf("git log: %w", err)
+	}
+
+	author, date, ok := strings.Cut(strings.TrimSpace(out.String()), "\x00")
+	if !ok {
+		return wikiCommit{}, fmt.Errorf("unexpected git log output %q", out.String())
+	}
+	when, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return wikiCommit{}, err
+	}
+
+	return wikiCommit{Author: author, Date: when}, nil
+}
+
+// Gets the environment that authenticates git with the token wiki probes to repoURL get, if they get one
+//
+// Only the wiki host gets the token, the same as probes. It goes in the environment rather than a -c argument,
+// where anyone on the machine could read it from the process list.
+func (s *Scanner) gitAuthEnv(repoURL string) []string {
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return nil
+	}
+	token := s.probeToken(u)
+	if token == "" {
+		return nil
+	}
+
+	basic := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + token))
+	return []string{
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=http.extraHeader",
+		"GIT_CONFIG_VALUE_0=Authorization: Basic " + basic,
+	}
+}
+
+// Runs git with extra environment variables without ever prompting for credentials, including its stderr in
+// the error
+func runGit(ctx context.Context, env []string, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0"), env...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// Whether a wiki's git repository has any pages committed, going by the branches it advertises
+//
+// GitHub only creates the repository with the first page, so a wiki without one isn't found at all. Unlike the
+// landing page, this doesn't depend on the page's language or markup.
+func (s *Scanner) wikiHasPages(ctx context.Context, client *http.Client, repoURL string) (bool, error) {
+	req, err := s.newProbeRequest(ctx, repoURL+".wiki.git/info/refs?service=git-upload-pack")
+	if err != nil {
+		return false, err
+	}
+	// Git over HTTPS takes the token as a password rather than a bearer token
+	if req.Header.Get("Authorization") != "" {
+		basic := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + s.tokens.primary()))
+		req.Header.Set("Authorization", "Basic "+basic)
+	}
+
+	resp, err := s.doProbeWithRetries(client, req)
+	if err != nil {
+		return false, err
+	}
+	defer drainAndClose(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		refs, err := io.ReadAll(io.LimitReader(resp.Body, maxDrainBytes))
+		if err != nil {
+			return false, fmt.Errorf("reading wiki refs: %w", err)
+		}
+		return bytes.Contains(refs, []byte("refs/heads/")), nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, NewHTTPError("fetch wiki refs", resp)
+	}
+}
@@ -0,0 +1,113 @@
+package scan
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGitAuthEnv(t *testing.T) {
+	basic := base64.StdEncoding.EncodeToString([]byte("x-access-token:secret"))
+
+	tests := []struct {
+		name      string
+		repoURL   string
+		anonymous bool
+		want      string
+	}{
+		{name: "wiki host", repoURL: "https://github.com/owner/repo", want: "Authorization: Basic " + basic},
+		{name: "other host", repoURL: "https://git.example.com/owner/repo"},
+		{name: "plain http", repoURL: "http://github.com/owner/repo"},
+		{name: "anonymous probes", repoURL: "https://github.com/owner/repo", anonymous: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner()
+			s.AddTokens("secret")
+			s.AnonymousProbes = tt.anonymous
+			env := s.gitAuthEnv(tt.repoURL)
+			if tt.want == "" {
+				if env != nil {
+					t.Fatalf("gitAuthEnv(%q) = %q, want no environment", tt.repoURL, env)
+				}
+				return
+			}
+
+			want := []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=http.extraHeader", "GIT_CONFIG_VALUE_0=" + tt.want}
+			if strings.Join(env, "\n") != strings.Join(want, "\n") {
+				t.Fatalf("gitAuthEnv(%q) = %q, want %q", tt.repoURL, env, want)
+			}
+		})
+	}
+}
+
+// Runs git in dir for test setup, with a fixed author and date and no user configuration
+func gitFixture(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_GLOBAL="+os.DevNull, "GIT_CONFIG_NOSYSTEM=1",
+		"GIT_AUTHOR_NAME=Mallory", "GIT_AUTHOR_EMAIL=mallory@example.com", "GIT_AUTHOR_DATE=2026-03-04T05:06:07Z",
+		"GIT_COMMITTER_NAME=Mallory", "GIT_COMMITTER_EMAIL=mallory@example.com", "GIT_COMMITTER_DATE=2026-03-04T05:06:07Z",
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+func TestGetLastWikiCommit(t *testing.T) {
+	git, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	// A wiki with two edits, the last by someone else, served the way GitHub serves them, as owner/repo.wiki.git
+	work, root := t.TempDir(), t.TempDir()
+	gitFixture(t, work, "init", "--quiet")
+	for _, page := range []string{"Home.md", "Defaced.md"} {
+		if err := os.WriteFile(filepath.Join(work, page), []byte("# "+page+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		gitFixture(t, work, "add", page)
+		commit := []string{"commit", "--quiet", "-m", "Add " + page}
+		if page == "Defaced.md" {
+			commit = append(commit, "--author", "Eve <eve@example.com>", "--date", "2026-05-06T07:08:09Z")
+		}
+		gitFixture(t, work, commit...)
+	}
+	bare := filepath.Join(root, "acme", "repo.wiki.git")
+	gitFixture(t, root, "clone", "--quiet", "--bare", work, bare)
+	gitFixture(t, bare, "config", "uploadpack.allowFilter", "true")
+
+	srv := httptest.NewServer(&cgi.Handler{
+		Path:   git,
+		Args:   []string{"http-backend"},
+		Env:    []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1"},
+		Stderr: io.Discard,
+	})
+	defer srv.Close()
+
+	s := NewScanner()
+	commit, err := s.getLastWikiCommit(context.Background(), srv.URL+"/acme/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2026, 5, 6, 7, 8, 9, 0, time.UTC)
+	if commit.Author != "Eve" || !commit.Date.Equal(want) {
+		t.Errorf("last commit = %q at %s, want Eve at %s", commit.Author, commit.Date, want)
+	}
+
+	if _, err := s.getLastWikiCommit(context.Background(), srv.URL+"/acme/missing"); err == nil {
+		t.Error("a wiki without a git repository had a last commit")
+	}
+}
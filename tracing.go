@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// scanTracer records spans for account scans and the probes within them, and can be shut down when it's done
+type scanTracer interface {
+	scan.Tracer
+	// Flushes any spans that haven't been exported
+	Shutdown(ctx context.Context) error
+}
+
+// Records spans for the scan, a no-op unless -otel-endpoint is set
+var tracer scanTracer = noopTracer{}
+
+// Creates the tracer exporting to an OTLP endpoint, only set when built with the otel tag
+var setupTracing func(endpoint string) (scanTracer, error)
+
+var errNoTracing = errors.New("this build doesn't support tracing, rebuild with -tags otel")
+
+// Sets up tracing to an OTLP endpoint
+func enableTracing(endpoint string) error {
+	if setupTracing == nil {
+		return errNoTracing
+	}
+
+	t, err := setupTracing(endpoint)
+	if err != nil {
+		return err
+	}
+	tracer = t
+
+	return nil
+}
+
+// noopTracer doesn't record anything
+type noopTracer struct{}
+
+func (noopTracer) StartAccount(ctx context.Context, account string) (context.Context, func(int, error)) {
+	return ctx, func(int, error) {}
+}
+
+func (noopTracer) StartProbe(ctx context.Context, account string, repo scan.Repository) (context.Context, func(scan.Probe, error)) {
+	return ctx, func(scan.Probe, error) {}
+}
+
+func (noopTracer) Shutdown(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,87 @@
+//go:build otel
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+func init() {
+	setupTracing = newOTelTracer
+}
+
+// otelTracer records scan spans with the OpenTelemetry SDK
+type otelTracer struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+// Creates a tracer that batches spans to an OTLP/HTTP endpoint
+func newOTelTracer(endpoint string) (scanTracer, error) {
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	return newOTelTracerWithExporter(exporter), nil
+}
+
+// Creates a tracer for any span exporter, so spans can also be collected in memory
+func newOTelTracerWithExporter(exporter sdktrace.SpanExporter) *otelTracer {
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "gitwiki"))),
+	)
+
+	return &otelTracer{provider: provider, tracer: provider.Tracer("github.com/offftherecord/gitwiki")}
+}
+
+func (t *otelTracer) StartAccount(ctx context.Context, account string) (context.Context, func(int, error)) {
+	ctx, span := t.tracer.Start(ctx, "scan account", trace.WithAttributes(attribute.String("gitwiki.account", account)))
+
+	return ctx, func(probed int, err error) {
+		span.SetAttributes(attribute.Int("gitwiki.repos_probed", probed))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+func (t *otelTracer) StartProbe(ctx context.Context, account string, repo scan.Repository) (context.Context, func(scan.Probe, error)) {
+	start := time.Now()
+	ctx, span := t.tracer.Start(ctx, "probe wiki", trace.WithAttributes(
+		attribute.String("gitwiki.account", account),
+		attribute.String("gitwiki.repo", repo.Name),
+		attribute.String("gitwiki.repo_url", repo.URL),
+	))
+
+	return ctx, func(p scan.Probe, err error) {
+		span.SetAttributes(
+			attribute.String("gitwiki.result", p.Result.String()),
+			attribute.String("gitwiki.url", p.URL),
+			attribute.Int("http.response.status_code", p.Status),
+			attribute.Int64("gitwiki.duration_ms", time.Since(start).Milliseconds()),
+		)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+func (t *otelTracer) Shutdown(ctx context.Context) error {
+	return t.provider.Shutdown(ctx)
+}
@@ -0,0 +1,99 @@
+//go:build otel
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+func TestProbeSpansNestUnderAccount(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel := newOTelTracerWithExporter(exporter)
+	defer otel.Shutdown(context.Background())
+
+	repos := []scan.Repository{
+		{Name: "one", URL: "https://github.test/acme/one", HasWiki: true, Visibility: "public"},
+		{Name: "two", URL: "https://github.test/acme/two", HasWiki: true, Visibility: "public"},
+		{Name: "three", URL: "https://github.test/acme/three", HasWiki: true, Visibility: "public"},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/acme/repos", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(repos)
+	})
+	api := httptest.NewServer(mux)
+	defer api.Close()
+
+	// Wiki probes never reach a server, the span each one went out on is all that's wanted
+	var mu sync.Mutex
+	seen := map[string]trace.SpanContext{}
+	scanner := scan.NewScanner()
+	scanner.APIURL = api.URL
+	scanner.ProbeRetries = 0
+	scanner.Concurrency = 2
+	scanner.Tracer = otel
+	scanner.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		seen[path.Base(path.Dir(req.URL.Path))] = trace.SpanContextFromContext(req.Context())
+		mu.Unlock()
+		return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody, Request: req}, nil
+	})}
+	if _, err := scanner.ScanAccount(context.Background(), "acme"); err != nil {
+		t.Fatal(err)
+	}
+	// Shutting down would also clear the in-memory exporter, so the spans are only flushed
+	if err := otel.provider.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var account tracetest.SpanStub
+	probes := map[string]tracetest.SpanStub{}
+	for _, s := range exporter.GetSpans() {
+		switch s.Name {
+		case "scan account":
+			account = s
+		case "probe wiki":
+			for _, a := range s.Attributes {
+				if a.Key == "gitwiki.repo" {
+					probes[a.Value.AsString()] = s
+				}
+			}
+		}
+	}
+	if !account.SpanContext.IsValid() {
+		t.Fatal("no account span was exported")
+	}
+	if len(probes) != len(repos) {
+		t.Fatalf("got %d probe spans, want %d", len(probes), len(repos))
+	}
+
+	for _, repo := range repos {
+		s := probes[repo.Name]
+		if s.Parent.SpanID() != account.SpanContext.SpanID() {
+			t.Errorf("%s: probe span's parent is %s, want the account span %s", repo.Name, s.Parent.SpanID(), account.SpanContext.SpanID())
+		}
+		if s.SpanContext.TraceID() != account.SpanContext.TraceID() {
+			t.Errorf("%s: probe span is in trace %s, want %s", repo.Name, s.SpanContext.TraceID(), account.SpanContext.TraceID())
+		}
+		if seen[repo.Name].SpanID() != s.SpanContext.SpanID() {
+			t.Errorf("%s: probe ran on span %s, want its own span %s", repo.Name, seen[repo.Name].SpanID(), s.SpanContext.SpanID())
+		}
+	}
+}
+
+// roundTripFunc stubs a transport with a function
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+)
+
+// Version, commit and date of this build, set at build time with -ldflags, such as
+// -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// Gets the version of this build, falling back to the module version go install records when -ldflags didn't
+// set one
+func buildVersion() string {
+	if version != "dev" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	return version
+}
+
+// Gets the commit and date of this build, falling back to what the go command stamps from version control
+func buildVCS() (string, string) {
+	rev, date := commit, buildDate
+	if info, ok := debug.ReadBuildInfo(); ok {
+		modified := false
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if rev == "" {
+					rev = s.Value
+				}
+			case "vcs.time":
+				if date == "" {
+					date = s.Value
+				}
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+		if commit == "" && rev != "" && modified {
+			rev += "-dirty"
+		}
+	}
+
+	return rev, date
+}
+
+// Writes what -version prints
+func writeVersion(w io.Writer) {
+	rev, date := buildVCS()
+	if rev == "" {
+		rev = "unknown"
+	}
+	if date == "" {
+		date = "unknown"
+	}
+
+	fmt.Fprintf(w, "gitwiki %s\ncommit: %s\nbuilt: %s\ngo: %s %s/%s\n", buildVersion(), rev, date, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
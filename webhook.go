@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/offftherecord/gitwiki/scan"
+)
+
+// How many findings the webhook reporter holds while the webhook is failing before dropping them
+const webhookQueueSize = 1000
+
+// How many times to try posting a finding before giving up on it
+const webhookAttempts = 5
+
+var errWebhookQueueFull = errors.New("webhook queue is full, dropped finding")
+
+// webhookPayload is what's posted for each finding, with text for Slack and the finding itself for anything else
+type webhookPayload struct {
+	Text    string        `json:"text"`
+	Finding findingRecord `json:"finding"`
+}
+
+// webhookReporter posts each finding as JSON to a webhook, such as a Slack incoming webhook
+//
+// Findings are queued and posted in the background, with retries on server errors, so a failing webhook never
+// stops the scan.
+type webhookReporter struct {
+	url    string
+	client *http.Client
+	// How the waits between attempts are randomized
+	jitter scan.Jitter
+	queue  chan scan.Finding
+	done   chan struct{}
+}
+
+func newWebhookReporter(url string, client *http.Client, jitter scan.Jitter) *webhookReporter {
+	r := &webhookReporter{
+		url:    url,
+		client: client,
+		jitter: jitter,
+		queue:  make(chan scan.Finding, webhookQueueSize),
+		done:   make(chan struct{}),
+	}
+	go r.run()
+
+	return r
+}
+
+func (r *webhookReporter) run() {
+	defer close(r.done)
+
+	for f := range r.queue {
+		text := fmt.Sprintf("%s: %s, URL: %s", textLabels[f.Result], f.Repo, f.URL)
+		body, err := json.Marshal(webhookPayload{Text: text, Finding: newFindingRecord(f)})
+		if err != nil {
+			logError("error", "post finding", f.Account, f.Repo, err)
+			continue
+		}
+
+		for attempt := 1; ; attempt++ {
+			var retry bool
+			retry, err = r.post(body)
+			if err == nil || !retry || attempt == webhookAttempts {
+				break
+			}
+			time.Sleep(r.jitter.Backoff(attempt, time.Second, 30*time.Second))
+		}
+		if err != nil {
+			logError("error", "post finding", f.Account, f.Repo, err)
+		}
+	}
+}
+
+// Posts a payload, returning whether it's worth trying again when it fails
+func (r *webhookReporter) post(body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, scan.NewHTTPError("post finding", resp)
+	}
+
+	return false, nil
+}
+
+func (r *webhookReporter) Report(f scan.Finding) error {
+	select {
+	case r.queue <- f:
+		return nil
+	default:
+		return errWebhookQueueFull
+	}
+}
+
+// Findings are posted in the background as soon as they're queued, so there's nothing to flush
+func (r *webhookReporter) Flush() error {
+	return nil
+}
+
+// Posts everything still queued
+func (r *webhookReporter) Close() error {
+	close(r.queue)
+	<-r.done
+
+	return nil
+}